@@ -6,31 +6,69 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/config"
 	"github.com/fernandoabolafio/lazybucket/internal/gcs"
+	"github.com/fernandoabolafio/lazybucket/internal/local"
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+	"github.com/fernandoabolafio/lazybucket/internal/s3"
 	"github.com/fernandoabolafio/lazybucket/internal/ui"
+	"google.golang.org/api/option"
 )
 
 func main() {
 	// Parse command line flags
-	var projectID string
+	var projectID, fixturePath, endpoint, provider, region, root string
+	var configPath, downloadDir, conflict string
+	var workers, viewerWindow int
 	flag.StringVar(&projectID, "project", "", "Google Cloud Project ID")
+	flag.StringVar(&provider, "provider", "", "Storage provider: gcs, s3 or local (defaults to the start path scheme, then gcs)")
+	flag.StringVar(&endpoint, "endpoint", "", "Storage endpoint: a GCS emulator such as localhost:4443 (defaults to STORAGE_EMULATOR_HOST) or an S3-compatible URL (defaults to AWS_ENDPOINT_URL)")
+	flag.StringVar(&region, "region", "", "S3 region (defaults to AWS_REGION)")
+	flag.StringVar(&root, "root", ".", "Directory browsed by the local provider; its subdirectories show as buckets")
+	flag.StringVar(&fixturePath, "fixture", "", "Browse an in-memory store seeded from a JSON fixture file instead of GCS")
+	flag.StringVar(&configPath, "config", "", "Config file (defaults to lazybucket/config.json in the user config directory)")
+	flag.StringVar(&downloadDir, "download-dir", "", "Directory downloads are written to (defaults to the current directory)")
+	flag.IntVar(&workers, "workers", 0, "Number of downloads, uploads and copies run in parallel (default 4)")
+	flag.StringVar(&conflict, "conflict", "", "What to do when a download would replace a file: overwrite, skip, rename or ask (default ask)")
+	flag.IntVar(&viewerWindow, "viewer-window", 0, "MiB of an object the viewer keeps in memory; larger text objects are fetched in chunks as you scroll (default 16)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [gs://bucket/prefix | s3://bucket/prefix | file:///directory]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	// Check if project ID is provided via environment variable if not specified via flag
-	if projectID == "" {
-		projectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	opts, err := loadOptions(configPath, downloadDir, workers, conflict, viewerWindow)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	// If still empty, prompt the user
-	if projectID == "" {
-		fmt.Println("Error: Google Cloud Project ID is required.")
-		fmt.Println("Please provide it using one of the following methods:")
-		fmt.Println("1. Command line flag: ./lazybucket --project=your-project-id")
-		fmt.Println("2. Environment variable: export GOOGLE_CLOUD_PROJECT=your-project-id")
-		os.Exit(1)
+	// The optional start path selects the provider through its scheme
+	scheme, startPath := splitScheme(flag.Arg(0))
+	if provider == "" {
+		provider = scheme
+	}
+
+	// A file:// start path names the root directory to browse
+	if scheme == "file" {
+		root = startPath
+		startPath = ""
+	}
+
+	// Browse a fixture without any cloud credentials
+	if fixturePath != "" {
+		store, err := memory.Load(fixturePath)
+		if err != nil {
+			fmt.Printf("Error loading fixture: %v\n", err)
+			os.Exit(1)
+		}
+		run(store, startPath, opts)
+		return
 	}
 
 	// Create a context that will be canceled on ctrl+c
@@ -46,21 +84,170 @@ func main() {
 		os.Exit(0)
 	}()
 
+	var client backend.Backend
+	switch provider {
+	case "", "gs", "gcs":
+		client = newGCSClient(ctx, projectID, endpoint)
+	case "s3":
+		client = newS3Client(endpoint, region)
+	case "file", "local":
+		localClient, err := local.NewClient(root)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		client = localClient
+	default:
+		fmt.Printf("Error: unknown provider %q (expected gcs, s3 or local)\n", provider)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	run(client, startPath, opts)
+}
+
+// newGCSClient creates a GCS client, exiting with guidance on failure
+func newGCSClient(ctx context.Context, projectID, endpoint string) *gcs.Client {
+	// Check if project ID is provided via environment variable if not specified via flag
+	if projectID == "" {
+		projectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+
+	// Use a storage emulator if one is configured
+	if endpoint == "" {
+		endpoint = os.Getenv("STORAGE_EMULATOR_HOST")
+	}
+	var opts []option.ClientOption
+	if endpoint != "" {
+		emulatorOpts, err := gcs.EmulatorOptions(endpoint)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = emulatorOpts
+
+		// Emulators ignore the project, but listing buckets requires one
+		if projectID == "" {
+			projectID = "test"
+		}
+	}
+
+	// If still empty, prompt the user
+	if projectID == "" {
+		fmt.Println("Error: Google Cloud Project ID is required.")
+		fmt.Println("Please provide it using one of the following methods:")
+		fmt.Println("1. Command line flag: ./lazybucket --project=your-project-id")
+		fmt.Println("2. Environment variable: export GOOGLE_CLOUD_PROJECT=your-project-id")
+		os.Exit(1)
+	}
+
 	// Create GCS client with project ID
-	gcsClient, err := gcs.NewClient(ctx, projectID)
+	gcsClient, err := gcs.NewClient(ctx, projectID, opts...)
 	if err != nil {
 		fmt.Printf("Error creating GCS client: %v\n", err)
 		fmt.Println("Make sure you are authenticated with Google Cloud:")
 		fmt.Println("  gcloud auth application-default login")
 		os.Exit(1)
 	}
-	defer gcsClient.Close()
 
-	// Create and start UI
-	model := ui.New(gcsClient)
+	return gcsClient
+}
+
+// newS3Client creates an S3 client, exiting with guidance on failure
+func newS3Client(endpoint, region string) *s3.Client {
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+
+	s3Client, err := s3.NewClient(s3.Config{
+		Endpoint: endpoint,
+		Region:   region,
+	})
+	if err != nil {
+		fmt.Printf("Error creating S3 client: %v\n", err)
+		fmt.Println("Make sure your AWS credentials are configured, e.g.:")
+		fmt.Println("  export AWS_ACCESS_KEY_ID=... AWS_SECRET_ACCESS_KEY=...")
+		os.Exit(1)
+	}
+
+	return s3Client
+}
+
+// loadOptions builds the UI options from the config file, with any flags
+// that were given taking precedence
+func loadOptions(configPath, downloadDir string, workers int, conflict string, viewerWindow int) (ui.Options, error) {
+	opts := ui.DefaultOptions()
+
+	if configPath == "" {
+		defaultPath, err := config.DefaultPath()
+		if err != nil {
+			return opts, nil
+		}
+		configPath = defaultPath
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return opts, err
+	}
+
+	if downloadDir == "" {
+		downloadDir = cfg.DownloadDir
+	}
+	if downloadDir != "" {
+		opts.DownloadDir = config.ExpandHome(downloadDir)
+	}
+
+	if workers == 0 {
+		workers = cfg.Workers
+	}
+	if workers > 0 {
+		opts.Workers = workers
+	}
+
+	if conflict == "" {
+		conflict = cfg.Conflict
+	}
+	if conflict != "" {
+		policy, err := ui.ParseConflictPolicy(conflict)
+		if err != nil {
+			return opts, err
+		}
+		opts.Conflict = policy
+	}
+
+	if viewerWindow == 0 {
+		viewerWindow = cfg.ViewerWindow
+	}
+	if viewerWindow > 0 {
+		opts.ViewerWindow = int64(viewerWindow) << 20
+	}
+
+	return opts, nil
+}
+
+// splitScheme splits a start path such as s3://bucket/prefix into its
+// scheme and bucket/prefix path
+func splitScheme(startPath string) (string, string) {
+	scheme, rest, ok := strings.Cut(startPath, "://")
+	if !ok {
+		return "", startPath
+	}
+	return scheme, rest
+}
+
+// run creates and starts the UI for the given backend
+func run(b backend.Backend, startPath string, opts ui.Options) {
+	model := ui.New(b, startPath, opts)
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
-	if err := p.Start(); err != nil {
+	final, err := p.Run()
+	if m, ok := final.(ui.Model); ok {
+		m.Close()
+	}
+	if err != nil {
 		fmt.Printf("Error running application: %v\n", err)
 		os.Exit(1)
 	}
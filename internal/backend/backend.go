@@ -0,0 +1,137 @@
+// Package backend defines the object store abstraction the UI browses.
+package backend
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Item represents a bucket, folder or object in an object store
+type Item struct {
+	Name      string
+	Path      string
+	FullPath  string
+	Size      int64
+	Updated   time.Time
+	IsDir     bool
+	IsBucket  bool
+	ParentDir string
+}
+
+// Attrs describes the content of an object as it is read
+type Attrs struct {
+	Size            int64
+	ContentType     string
+	ContentEncoding string
+
+	// Generation identifies the version of the object that is read and
+	// changes whenever the object is overwritten. It is empty if the store
+	// cannot tell versions apart.
+	Generation string
+
+	// CRC32C is the Castagnoli CRC32 of the content and is only valid when
+	// HasCRC32C is set
+	CRC32C    uint32
+	HasCRC32C bool
+
+	// MD5 is the MD5 hash of the content, or nil if the store does not
+	// record one
+	MD5 []byte
+}
+
+// Backend is implemented by every object store that can be browsed. Every
+// operation takes its own context so that it can be cancelled on its own.
+type Backend interface {
+	// ListBuckets lists all buckets visible to the backend
+	ListBuckets(ctx context.Context) ([]Item, error)
+
+	// ListObjects lists one page of at most pageSize objects in a bucket
+	// with the given prefix. An empty pageToken starts a new listing and
+	// the returned token is empty once the listing is exhausted.
+	ListObjects(ctx context.Context, bucketName, prefix, pageToken string, pageSize int) ([]Item, string, error)
+
+	// ListRecursive lists every object below prefix, descending into all
+	// sub-prefixes. Only objects are returned, never directories.
+	ListRecursive(ctx context.Context, bucketName, prefix string) ([]Item, error)
+
+	// NewReader opens a streaming reader for an object's content and
+	// returns the object's attributes. The caller must close the reader.
+	NewReader(ctx context.Context, bucketName, objectName string) (io.ReadCloser, Attrs, error)
+
+	// NewRangeReader opens a streaming reader for length bytes of an
+	// object's content starting at offset, or for the rest of the content
+	// if length is negative. The returned attributes describe the whole
+	// object, not just the range.
+	NewRangeReader(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, Attrs, error)
+
+	// Upload stores the contents of r, which holds size bytes, as an object.
+	// Backends use resumable or multipart uploads where available.
+	Upload(ctx context.Context, bucketName, objectName string, r io.Reader, size int64) error
+
+	// Delete removes a single object
+	Delete(ctx context.Context, bucketName, objectName string) error
+
+	// Copy copies an object, server-side where the backend supports it.
+	// Source and destination may be in different buckets.
+	Copy(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error
+
+	// Move moves an object, possibly to another bucket
+	Move(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error
+
+	// URL returns the canonical URL of an object, e.g. gs://bucket/object
+	URL(bucketName, objectName string) string
+
+	// Close releases any resources held by the backend
+	Close() error
+}
+
+// DefaultPageSize is the number of entries requested per listing page
+const DefaultPageSize = 1000
+
+// ParsePath parses a full path into bucket name and prefix
+func ParsePath(fullPath string) (string, string) {
+	parts := strings.SplitN(fullPath, "/", 2)
+
+	bucketName := parts[0]
+	prefix := ""
+
+	if len(parts) > 1 {
+		prefix = parts[1]
+	}
+
+	return bucketName, prefix
+}
+
+// NormalizePrefix ensures a non-empty prefix ends with a slash
+func NormalizePrefix(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		return prefix + "/"
+	}
+	return prefix
+}
+
+// ParentPrefix returns the prefix of the folder containing objectName,
+// including the trailing slash, or "" for objects at the bucket root
+func ParentPrefix(objectName string) string {
+	return objectName[:strings.LastIndex(objectName, "/")+1]
+}
+
+// UpItem returns the ".." item used to navigate from prefix to its parent
+func UpItem(bucketName, prefix string) Item {
+	parentDir := ""
+	parts := strings.Split(strings.TrimSuffix(prefix, "/"), "/")
+	if len(parts) > 0 {
+		parentDir = strings.Join(parts[:len(parts)-1], "/")
+	}
+
+	return Item{
+		Name:      "..",
+		Path:      parentDir,
+		FullPath:  path.Join(bucketName, parentDir),
+		IsDir:     true,
+		ParentDir: parentDir,
+	}
+}
@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"hash/crc32"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Verifier hashes the content written to it and checks it against the
+// checksums recorded for an object
+type Verifier struct {
+	attrs Attrs
+	crc   hash.Hash32
+	md5   hash.Hash
+}
+
+// NewVerifier creates a verifier for an object with the given attributes
+func NewVerifier(attrs Attrs) *Verifier {
+	return &Verifier{
+		attrs: attrs,
+		crc:   crc32.New(crc32cTable),
+		md5:   md5.New(),
+	}
+}
+
+// Write implements io.Writer
+func (v *Verifier) Write(p []byte) (int, error) {
+	v.crc.Write(p)
+	v.md5.Write(p)
+	return len(p), nil
+}
+
+// Verify checks the content written so far against the object's CRC32C and
+// MD5. Checksums the store did not record are not checked.
+func (v *Verifier) Verify() error {
+	if v.attrs.HasCRC32C && v.crc.Sum32() != v.attrs.CRC32C {
+		return fmt.Errorf("CRC32C mismatch: got %08x, want %08x", v.crc.Sum32(), v.attrs.CRC32C)
+	}
+	if v.attrs.MD5 != nil && !bytes.Equal(v.md5.Sum(nil), v.attrs.MD5) {
+		return fmt.Errorf("MD5 mismatch: got %x, want %x", v.md5.Sum(nil), v.attrs.MD5)
+	}
+	return nil
+}
+
+// Checked reports whether the object has any checksum to verify against
+func (v *Verifier) Checked() bool {
+	return v.attrs.HasCRC32C || v.attrs.MD5 != nil
+}
@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// chunkSize is the size of the aligned chunks a RangeReaderAt fetches
+	chunkSize = 1 << 20
+
+	// cachedChunks is the number of chunks a RangeReaderAt keeps
+	cachedChunks = 32
+)
+
+// RangeReaderAt reads an object with ranged reads. Reads are served from
+// aligned chunks that are fetched on demand and cached, so that the many
+// small reads file format decoders make cost few requests. Every chunk must
+// come from the same generation of the object.
+type RangeReaderAt struct {
+	ctx        context.Context
+	backend    Backend
+	bucketName string
+	objectName string
+	size       int64
+
+	mu         sync.Mutex
+	generation string
+	chunks     map[int64][]byte
+	order      []int64
+	fetched    int64
+	requests   int
+}
+
+// NewRangeReaderAt creates a reader for an object of the given size
+func NewRangeReaderAt(ctx context.Context, b Backend, bucketName, objectName string, size int64) *RangeReaderAt {
+	return &RangeReaderAt{
+		ctx:        ctx,
+		backend:    b,
+		bucketName: bucketName,
+		objectName: objectName,
+		size:       size,
+		chunks:     make(map[int64][]byte),
+	}
+}
+
+// Size returns the size of the object
+func (r *RangeReaderAt) Size() int64 {
+	return r.size
+}
+
+// Fetched returns the number of bytes fetched so far and the number of
+// requests that took
+func (r *RangeReaderAt) Fetched() (int64, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.fetched, r.requests
+}
+
+// ReadAt implements io.ReaderAt
+func (r *RangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+
+		start := pos - pos%chunkSize
+		chunk, err := r.chunk(start)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], chunk[pos-start:])
+	}
+	return n, nil
+}
+
+// chunk returns the chunk starting at start, fetching it if needed
+func (r *RangeReaderAt) chunk(start int64) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if chunk, ok := r.chunks[start]; ok {
+		return chunk, nil
+	}
+
+	length := min(chunkSize, r.size-start)
+	reader, attrs, err := r.backend.NewRangeReader(r.ctx, r.bucketName, r.objectName, start, length)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if r.requests > 0 && attrs.Generation != r.generation {
+		return nil, fmt.Errorf("%s changed while it was being read", r.objectName)
+	}
+	r.generation = attrs.Generation
+
+	chunk := make([]byte, length)
+	if _, err := io.ReadFull(reader, chunk); err != nil {
+		return nil, fmt.Errorf("error reading object: %v", err)
+	}
+	r.fetched += length
+	r.requests++
+
+	// Evict the oldest chunk once the cache is full
+	if len(r.order) >= cachedChunks {
+		delete(r.chunks, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.chunks[start] = chunk
+	r.order = append(r.order, start)
+	return chunk, nil
+}
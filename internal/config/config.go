@@ -0,0 +1,81 @@
+// Package config loads user settings from a JSON config file of the form:
+//
+//	{
+//	  "downloadDir": "~/Downloads/buckets",
+//	  "workers": 8,
+//	  "conflict": "rename",
+//	  "viewerWindow": 32
+//	}
+//
+// Every setting is optional. Command line flags override the file.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds the settings read from the config file
+type Config struct {
+	// DownloadDir is the directory downloads are written to
+	DownloadDir string `json:"downloadDir"`
+
+	// Workers is the number of transfers run in parallel
+	Workers int `json:"workers"`
+
+	// Conflict is what to do when a download would overwrite a file:
+	// overwrite, skip, rename or ask
+	Conflict string `json:"conflict"`
+
+	// ViewerWindow is the number of MiB of an object the viewer keeps in
+	// memory
+	ViewerWindow int `json:"viewerWindow"`
+}
+
+// DefaultPath returns the location of the config file,
+// lazybucket/config.json in the user's config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lazybucket", "config.json"), nil
+}
+
+// Load reads the config file at path. A missing file yields an empty
+// config.
+func Load(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("error reading config: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("error parsing config %s: %v", path, err)
+	}
+	cfg.DownloadDir = ExpandHome(cfg.DownloadDir)
+
+	return cfg, nil
+}
+
+// ExpandHome replaces a leading ~ in path with the user's home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
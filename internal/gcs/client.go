@@ -4,33 +4,31 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"path"
+	"strconv"
 	"strings"
-	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+// uploadChunkSize is the size of each chunk of a resumable upload
+const uploadChunkSize = 16 << 20
+
 // Client represents a Google Cloud Storage client
 type Client struct {
 	client    *storage.Client
-	ctx       context.Context
 	projectID string
 }
 
 // Item represents a bucket, folder or object in GCS
-type Item struct {
-	Name      string
-	Path      string
-	FullPath  string
-	Size      int64
-	Updated   time.Time
-	IsDir     bool
-	IsBucket  bool
-	ParentDir string
-}
+type Item = backend.Item
+
+// Client implements backend.Backend
+var _ backend.Backend = (*Client)(nil)
 
 // NewClient creates a new GCS client
 func NewClient(ctx context.Context, projectID string, opts ...option.ClientOption) (*Client, error) {
@@ -41,22 +39,49 @@ func NewClient(ctx context.Context, projectID string, opts ...option.ClientOptio
 
 	return &Client{
 		client:    client,
-		ctx:       ctx,
 		projectID: projectID,
 	}, nil
 }
 
+// EmulatorOptions returns client options that point the client at a storage
+// emulator such as fake-gcs-server with authentication disabled. The endpoint
+// may be a bare host:port, as accepted by STORAGE_EMULATOR_HOST, or a URL.
+func EmulatorOptions(endpoint string) ([]option.ClientOption, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid emulator endpoint %q: %v", endpoint, err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/storage/v1/"
+	}
+
+	return []option.ClientOption{
+		option.WithEndpoint(u.String()),
+		option.WithoutAuthentication(),
+		storage.WithJSONReads(),
+	}, nil
+}
+
 // Close closes the GCS client
 func (c *Client) Close() error {
 	return c.client.Close()
 }
 
+// URL returns the gs:// URL of an object
+func (c *Client) URL(bucketName, objectName string) string {
+	return fmt.Sprintf("gs://%s/%s", bucketName, objectName)
+}
+
 // ListBuckets lists all buckets in the GCS project
-func (c *Client) ListBuckets() ([]Item, error) {
+func (c *Client) ListBuckets(ctx context.Context) ([]Item, error) {
 	var items []Item
 
 	// Use the project ID when listing buckets
-	it := c.client.Buckets(c.ctx, c.projectID)
+	it := c.client.Buckets(ctx, c.projectID)
 
 	for {
 		bucketAttrs, err := it.Next()
@@ -80,58 +105,34 @@ func (c *Client) ListBuckets() ([]Item, error) {
 	return items, nil
 }
 
-// ListObjects lists objects in a bucket with the given prefix
-func (c *Client) ListObjects(bucketName, prefix string) ([]Item, error) {
+// ListObjects lists one page of objects in a bucket with the given prefix
+func (c *Client) ListObjects(ctx context.Context, bucketName, prefix, pageToken string, pageSize int) ([]Item, string, error) {
 	var items []Item
 	bucket := c.client.Bucket(bucketName)
 
-	// If prefix doesn't end with a slash and is not empty, add a slash
-	if prefix != "" && !strings.HasSuffix(prefix, "/") {
-		prefix = prefix + "/"
-	}
+	prefix = backend.NormalizePrefix(prefix)
 
-	// Get parent directory
-	parentDir := ""
-	if prefix != "" {
-		parts := strings.Split(strings.TrimSuffix(prefix, "/"), "/")
-		if len(parts) > 0 {
-			parentDir = strings.Join(parts[:len(parts)-1], "/")
-		}
+	// Add a special item to navigate up a directory on the first page
+	if prefix != "" && pageToken == "" {
+		items = append(items, backend.UpItem(bucketName, prefix))
 	}
 
-	// Add a special item to navigate up a directory
-	if prefix != "" {
-		items = append(items, Item{
-			Name:      "..",
-			Path:      parentDir,
-			FullPath:  path.Join(bucketName, parentDir),
-			IsDir:     true,
-			ParentDir: parentDir,
-		})
-	}
-
-	// Create a map to track directories
-	directories := make(map[string]bool)
-
-	it := bucket.Objects(c.ctx, &storage.Query{
+	it := bucket.Objects(ctx, &storage.Query{
 		Prefix:    prefix,
 		Delimiter: "/",
 	})
 
-	// Process common prefixes (directories)
-	for {
-		attrs, err := it.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("error listing objects: %v", err)
-		}
+	var page []*storage.ObjectAttrs
+	nextPageToken, err := iterator.NewPager(it, pageSize, pageToken).NextPage(&page)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing objects: %v", err)
+	}
 
+	// Process common prefixes (directories) and files
+	for _, attrs := range page {
 		if attrs.Prefix != "" {
 			// This is a directory
 			dirName := path.Base(strings.TrimSuffix(attrs.Prefix, "/"))
-			directories[dirName] = true
 			items = append(items, Item{
 				Name:      dirName,
 				Path:      attrs.Prefix,
@@ -162,38 +163,110 @@ func (c *Client) ListObjects(bucketName, prefix string) ([]Item, error) {
 		}
 	}
 
+	return items, nextPageToken, nil
+}
+
+// ListRecursive lists every object below prefix
+func (c *Client) ListRecursive(ctx context.Context, bucketName, prefix string) ([]Item, error) {
+	var items []Item
+
+	it := c.client.Bucket(bucketName).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing objects: %v", err)
+		}
+
+		items = append(items, Item{
+			Name:      path.Base(attrs.Name),
+			Path:      attrs.Name,
+			FullPath:  path.Join(bucketName, attrs.Name),
+			Size:      attrs.Size,
+			Updated:   attrs.Updated,
+			ParentDir: backend.ParentPrefix(attrs.Name),
+		})
+	}
+
 	return items, nil
 }
 
-// GetObjectContent gets the content of an object as a string
-func (c *Client) GetObjectContent(bucketName, objectName string) (string, error) {
-	bucket := c.client.Bucket(bucketName)
-	obj := bucket.Object(objectName)
+// NewReader opens a streaming reader for an object. The CRC32C is only
+// reported when the content is served as stored, since objects that GCS
+// decompresses on the fly no longer match it.
+func (c *Client) NewReader(ctx context.Context, bucketName, objectName string) (io.ReadCloser, backend.Attrs, error) {
+	return c.NewRangeReader(ctx, bucketName, objectName, 0, -1)
+}
 
-	reader, err := obj.NewReader(c.ctx)
+// NewRangeReader opens a streaming reader for a range of an object. No
+// generation is reported for objects that GCS decompresses on the fly,
+// since their ranges refer to the compressed bytes rather than the content
+// that is read.
+func (c *Client) NewRangeReader(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, backend.Attrs, error) {
+	reader, err := c.client.Bucket(bucketName).Object(objectName).NewRangeReader(ctx, offset, length)
 	if err != nil {
-		return "", fmt.Errorf("error opening object: %v", err)
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
 	}
-	defer reader.Close()
 
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return "", fmt.Errorf("error reading object: %v", err)
+	attrs := backend.Attrs{
+		Size:            reader.Attrs.Size,
+		ContentType:     reader.Attrs.ContentType,
+		ContentEncoding: reader.Attrs.ContentEncoding,
+		CRC32C:          reader.Attrs.CRC32C,
+		HasCRC32C:       reader.Attrs.CRC32C != 0 && !reader.Attrs.Decompressed,
 	}
+	if !reader.Attrs.Decompressed {
+		attrs.Generation = strconv.FormatInt(reader.Attrs.Generation, 10)
+	}
+	return reader, attrs, nil
+}
 
-	return string(data), nil
+// Upload uploads the contents of r as an object using a resumable upload
+func (c *Client) Upload(ctx context.Context, bucketName, objectName string, r io.Reader, size int64) error {
+	w := c.client.Bucket(bucketName).Object(objectName).NewWriter(ctx)
+	w.ChunkSize = uploadChunkSize
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+
+	return nil
 }
 
-// ParsePath parses a full path into bucket name and prefix
-func ParsePath(fullPath string) (string, string) {
-	parts := strings.SplitN(fullPath, "/", 2)
+// Delete deletes an object
+func (c *Client) Delete(ctx context.Context, bucketName, objectName string) error {
+	if err := c.client.Bucket(bucketName).Object(objectName).Delete(ctx); err != nil {
+		return fmt.Errorf("error deleting %s: %v", objectName, err)
+	}
+	return nil
+}
 
-	bucketName := parts[0]
-	prefix := ""
+// Copy copies an object server-side using the rewrite API, which handles
+// objects of any size and copies across buckets and locations
+func (c *Client) Copy(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	src := c.client.Bucket(srcBucket).Object(srcObject)
+	dst := c.client.Bucket(dstBucket).Object(dstObject)
 
-	if len(parts) > 1 {
-		prefix = parts[1]
+	if _, err := dst.CopierFrom(src).Run(ctx); err != nil {
+		return fmt.Errorf("error copying %s to %s: %v", srcObject, dstObject, err)
 	}
+	return nil
+}
 
-	return bucketName, prefix
+// Move moves an object by copying it and deleting the source. An object
+// moved onto itself is left alone rather than deleted after the copy.
+func (c *Client) Move(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	if srcBucket == dstBucket && srcObject == dstObject {
+		return nil
+	}
+	if err := c.Copy(ctx, srcBucket, srcObject, dstBucket, dstObject); err != nil {
+		return err
+	}
+	return c.Delete(ctx, srcBucket, srcObject)
 }
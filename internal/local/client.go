@@ -0,0 +1,396 @@
+// Package local implements a backend that browses a directory tree on the
+// local filesystem. Top-level subdirectories of the root are presented as
+// buckets and everything below them as objects and prefixes.
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+)
+
+// Client represents a local filesystem backend rooted at a directory
+type Client struct {
+	root string
+}
+
+// Client implements backend.Backend
+var _ backend.Backend = (*Client)(nil)
+
+// NewClient creates a new local filesystem backend rooted at root
+func NewClient(root string) (*Client, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root %s: %v", root, err)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open root: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("root %s is not a directory", abs)
+	}
+
+	return &Client{root: abs}, nil
+}
+
+// Close implements backend.Backend
+func (c *Client) Close() error {
+	return nil
+}
+
+// URL returns the file:// URL of an object
+func (c *Client) URL(bucketName, objectName string) string {
+	return "file://" + filepath.ToSlash(filepath.Join(c.root, bucketName, filepath.FromSlash(objectName)))
+}
+
+// localPath maps a bucket and object name onto a path below the root,
+// refusing names that would escape it
+func (c *Client) localPath(bucketName, objectName string) (string, error) {
+	name := path.Join(bucketName, objectName)
+	if bucketName == "" || strings.Contains(bucketName, "/") || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("invalid path %q", name)
+	}
+	return filepath.Join(c.root, filepath.FromSlash(name)), nil
+}
+
+// ListBuckets lists the subdirectories of the root as buckets
+func (c *Client) ListBuckets(ctx context.Context) ([]backend.Item, error) {
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		return nil, fmt.Errorf("error listing buckets: %v", err)
+	}
+
+	var items []backend.Item
+	for _, entry := range entries {
+		info, err := os.Stat(filepath.Join(c.root, entry.Name()))
+		if err != nil || !info.IsDir() {
+			continue
+		}
+
+		items = append(items, backend.Item{
+			Name:     entry.Name(),
+			Path:     entry.Name(),
+			FullPath: entry.Name(),
+			Updated:  info.ModTime(),
+			IsDir:    true,
+			IsBucket: true,
+		})
+	}
+
+	return items, nil
+}
+
+// ListObjects lists one page of files and directories in a bucket with the
+// given prefix. The page token is the last entry name of the previous page.
+func (c *Client) ListObjects(ctx context.Context, bucketName, prefix, pageToken string, pageSize int) ([]backend.Item, string, error) {
+	var items []backend.Item
+
+	prefix = backend.NormalizePrefix(prefix)
+	if pageSize <= 0 {
+		pageSize = backend.DefaultPageSize
+	}
+
+	dir, err := c.localPath(bucketName, prefix)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing objects: %v", err)
+	}
+
+	// Add a special item to navigate up a directory on the first page
+	if prefix != "" && pageToken == "" {
+		items = append(items, backend.UpItem(bucketName, prefix))
+	}
+
+	// Entries are sorted by name, so resume after the previous page
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing objects: %v", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	start := sort.Search(len(entries), func(i int) bool {
+		return pageToken == "" || entries[i].Name() > pageToken
+	})
+	entries = entries[start:]
+
+	nextPageToken := ""
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		nextPageToken = entries[pageSize-1].Name()
+	}
+
+	for _, entry := range entries {
+		// Follow symlinks so linked snapshots can be browsed too
+		info, err := os.Stat(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		objectName := prefix + entry.Name()
+		if info.IsDir() {
+			items = append(items, backend.Item{
+				Name:      entry.Name(),
+				Path:      objectName + "/",
+				FullPath:  path.Join(bucketName, objectName),
+				Updated:   info.ModTime(),
+				IsDir:     true,
+				ParentDir: prefix,
+			})
+			continue
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		items = append(items, backend.Item{
+			Name:      entry.Name(),
+			Path:      objectName,
+			FullPath:  path.Join(bucketName, objectName),
+			Size:      info.Size(),
+			Updated:   info.ModTime(),
+			ParentDir: prefix,
+		})
+	}
+
+	return items, nextPageToken, nil
+}
+
+// ListRecursive lists every file below prefix
+func (c *Client) ListRecursive(ctx context.Context, bucketName, prefix string) ([]backend.Item, error) {
+	root, err := c.localPath(bucketName, "")
+	if err != nil {
+		return nil, fmt.Errorf("error listing objects: %v", err)
+	}
+
+	// Walk the deepest directory named by the prefix and filter the rest
+	start, err := c.localPath(bucketName, backend.ParentPrefix(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("error listing objects: %v", err)
+	}
+
+	var items []backend.Item
+	err = filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Like in an object store, a prefix of no files lists nothing
+			if p == start && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		objectName := filepath.ToSlash(rel)
+		if !strings.HasPrefix(objectName, prefix) {
+			return nil
+		}
+
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		items = append(items, backend.Item{
+			Name:      path.Base(objectName),
+			Path:      objectName,
+			FullPath:  path.Join(bucketName, objectName),
+			Size:      info.Size(),
+			Updated:   info.ModTime(),
+			ParentDir: backend.ParentPrefix(objectName),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing objects: %v", err)
+	}
+
+	return items, nil
+}
+
+// NewReader opens a reader for a file. Files carry no checksums, so none
+// are reported.
+func (c *Client) NewReader(ctx context.Context, bucketName, objectName string) (io.ReadCloser, backend.Attrs, error) {
+	return c.NewRangeReader(ctx, bucketName, objectName, 0, -1)
+}
+
+// NewRangeReader opens a reader for a range of a file. Its generation is
+// derived from the file's size and modification time.
+func (c *Client) NewRangeReader(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, backend.Attrs, error) {
+	p, err := c.localPath(bucketName, objectName)
+	if err != nil {
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+
+	var reader io.ReadCloser = f
+	if length >= 0 {
+		reader = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(f, length), f}
+	}
+	return reader, backend.Attrs{
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(p)),
+		Generation:  fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()),
+	}, nil
+}
+
+// Upload writes the contents of r to a file, creating parent directories as
+// needed. The file only appears once it has been written completely.
+func (c *Client) Upload(ctx context.Context, bucketName, objectName string, r io.Reader, size int64) error {
+	return c.write(ctx, bucketName, objectName, r, 0o644)
+}
+
+// write writes the contents of r to a file with the given permissions
+// through a temporary file, which is created readable by its owner only
+func (c *Client) write(ctx context.Context, bucketName, objectName string, r io.Reader, perm os.FileMode) error {
+	p, err := c.localPath(bucketName, objectName)
+	if err != nil {
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), ".lazybucket-upload-*")
+	if err != nil {
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, contextReader{ctx, r}); err != nil {
+		f.Close()
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+
+	return nil
+}
+
+// Delete removes a file, then any directories it leaves empty so that
+// deleted folders disappear like prefixes do
+func (c *Client) Delete(ctx context.Context, bucketName, objectName string) error {
+	p, err := c.localPath(bucketName, objectName)
+	if err != nil {
+		return fmt.Errorf("error deleting %s: %v", objectName, err)
+	}
+	if err := os.Remove(p); err != nil {
+		return fmt.Errorf("error deleting %s: %v", objectName, err)
+	}
+
+	c.removeEmptyParents(bucketName, p)
+
+	return nil
+}
+
+// removeEmptyParents removes the directories above p that are left empty,
+// stopping at the bucket directory
+func (c *Client) removeEmptyParents(bucketName, p string) {
+	bucketDir := filepath.Join(c.root, bucketName)
+	for dir := filepath.Dir(p); dir != bucketDir && strings.HasPrefix(dir, bucketDir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+// Copy copies a file, keeping its permissions
+func (c *Client) Copy(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	src, err := c.localPath(srcBucket, srcObject)
+	if err != nil {
+		return fmt.Errorf("error copying %s: %v", srcObject, err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("error copying %s: %v", srcObject, err)
+	}
+	reader, _, err := c.NewReader(ctx, srcBucket, srcObject)
+	if err != nil {
+		return fmt.Errorf("error copying %s: %v", srcObject, err)
+	}
+	defer reader.Close()
+
+	return c.write(ctx, dstBucket, dstObject, reader, info.Mode().Perm())
+}
+
+// Move renames a file, creating parent directories as needed. A file
+// moved onto itself is left in place.
+func (c *Client) Move(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	if srcBucket == dstBucket && srcObject == dstObject {
+		return nil
+	}
+	src, err := c.localPath(srcBucket, srcObject)
+	if err != nil {
+		return fmt.Errorf("error moving %s: %v", srcObject, err)
+	}
+	dst, err := c.localPath(dstBucket, dstObject)
+	if err != nil {
+		return fmt.Errorf("error moving %s: %v", srcObject, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("error moving %s: %v", srcObject, err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("error moving %s: %v", srcObject, err)
+	}
+	c.removeEmptyParents(srcBucket, src)
+
+	return nil
+}
+
+// contextReader stops reading once its context is cancelled
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,52 @@
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadAndCopyPermissions(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "bucket"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := c.Upload(ctx, "bucket", "dir/a.txt", strings.NewReader("a"), 1); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(root, "bucket", "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(script, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Copy(ctx, "bucket", "run.sh", "bucket", "copy/run.sh"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want os.FileMode
+	}{
+		{name: "dir/a.txt", want: 0o644},
+		{name: "copy/run.sh", want: 0o750},
+	}
+	for _, tt := range tests {
+		info, err := os.Stat(filepath.Join(root, "bucket", filepath.FromSlash(tt.name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != tt.want {
+			t.Errorf("%s has mode %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+// Package memory implements an in-memory object store backend.
+//
+// It behaves like a bucket-based object store (delimited listings, common
+// prefixes as directories) without touching the network, which makes it
+// useful for exercising the UI offline and for demos. A store can be seeded
+// from a JSON fixture file of the form:
+//
+//	{
+//	  "buckets": [
+//	    {
+//	      "name": "my-bucket",
+//	      "objects": [
+//	        {"name": "logs/app.log", "content": "hello\n", "contentType": "text/plain"},
+//	        {"name": "bin/blob", "data": "AAECAw==", "metadata": {"owner": "ci"}}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// "content" holds text while "data" holds base64 encoded bytes.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+)
+
+// Object is an object stored in memory
+type Object struct {
+	Data        []byte
+	ContentType string
+	Metadata    map[string]string
+	Updated     time.Time
+
+	// ContentEncoding is reported when the object is read, while Data is
+	// served as it is, like GCS serves objects it decodes on the fly
+	ContentEncoding string
+
+	// Generation is assigned by the store and increases with every write
+	Generation int64
+}
+
+type bucket struct {
+	created time.Time
+	objects map[string]Object
+}
+
+// Backend is an in-memory object store
+type Backend struct {
+	mu         sync.RWMutex
+	buckets    map[string]*bucket
+	generation int64
+}
+
+// Backend implements backend.Backend
+var _ backend.Backend = (*Backend)(nil)
+
+// Fixture describes the initial contents of an in-memory store
+type Fixture struct {
+	Buckets []FixtureBucket `json:"buckets"`
+}
+
+// FixtureBucket describes a bucket and its objects in a fixture
+type FixtureBucket struct {
+	Name    string          `json:"name"`
+	Created time.Time       `json:"created"`
+	Objects []FixtureObject `json:"objects"`
+}
+
+// FixtureObject describes a single object in a fixture
+type FixtureObject struct {
+	Name            string            `json:"name"`
+	Content         string            `json:"content"`
+	Data            []byte            `json:"data"`
+	ContentType     string            `json:"contentType"`
+	ContentEncoding string            `json:"contentEncoding"`
+	Metadata        map[string]string `json:"metadata"`
+	Updated         time.Time         `json:"updated"`
+}
+
+// New creates an empty in-memory store
+func New() *Backend {
+	return &Backend{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// NewFromFixture creates an in-memory store seeded with the given fixture
+func NewFromFixture(f Fixture) *Backend {
+	b := New()
+	for _, fb := range f.Buckets {
+		b.CreateBucket(fb.Name, fb.Created)
+		for _, fo := range fb.Objects {
+			data := fo.Data
+			if data == nil {
+				data = []byte(fo.Content)
+			}
+			b.PutObject(fb.Name, fo.Name, Object{
+				Data:            data,
+				ContentType:     fo.ContentType,
+				ContentEncoding: fo.ContentEncoding,
+				Metadata:        fo.Metadata,
+				Updated:         fo.Updated,
+			})
+		}
+	}
+	return b
+}
+
+// Load creates an in-memory store seeded from a JSON fixture file
+func Load(fixturePath string) (*Backend, error) {
+	data, err := os.ReadFile(fixturePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixture: %v", err)
+	}
+
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("error parsing fixture %s: %v", fixturePath, err)
+	}
+
+	return NewFromFixture(f), nil
+}
+
+// CreateBucket creates a bucket if it does not exist yet
+func (b *Backend) CreateBucket(name string, created time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.buckets[name]; ok {
+		return
+	}
+	b.buckets[name] = &bucket{
+		created: created,
+		objects: make(map[string]Object),
+	}
+}
+
+// PutObject stores an object, creating its bucket if needed
+func (b *Backend) PutObject(bucketName, objectName string, obj Object) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bkt, ok := b.buckets[bucketName]
+	if !ok {
+		bkt = &bucket{objects: make(map[string]Object)}
+		b.buckets[bucketName] = bkt
+	}
+	if obj.Updated.IsZero() {
+		obj.Updated = time.Now()
+	}
+	b.generation++
+	obj.Generation = b.generation
+	bkt.objects[objectName] = obj
+}
+
+// Object returns a stored object
+func (b *Backend) Object(bucketName, objectName string) (Object, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bkt, ok := b.buckets[bucketName]
+	if !ok {
+		return Object{}, false
+	}
+	obj, ok := bkt.objects[objectName]
+	return obj, ok
+}
+
+// Close implements backend.Backend
+func (b *Backend) Close() error {
+	return nil
+}
+
+// URL returns the mem:// URL of an object
+func (b *Backend) URL(bucketName, objectName string) string {
+	return fmt.Sprintf("mem://%s/%s", bucketName, objectName)
+}
+
+// ListBuckets lists all buckets in the store
+func (b *Backend) ListBuckets(ctx context.Context) ([]backend.Item, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	names := make([]string, 0, len(b.buckets))
+	for name := range b.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := make([]backend.Item, 0, len(names))
+	for _, name := range names {
+		items = append(items, backend.Item{
+			Name:     name,
+			Path:     name,
+			FullPath: name,
+			Updated:  b.buckets[name].created,
+			IsDir:    true,
+			IsBucket: true,
+		})
+	}
+
+	return items, nil
+}
+
+// ListObjects lists one page of objects in a bucket with the given prefix.
+// The page token is the last entry of the previous page.
+func (b *Backend) ListObjects(ctx context.Context, bucketName, prefix, pageToken string, pageSize int) ([]backend.Item, string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bkt, ok := b.buckets[bucketName]
+	if !ok {
+		return nil, "", fmt.Errorf("error listing objects: bucket %q not found", bucketName)
+	}
+
+	prefix = backend.NormalizePrefix(prefix)
+	if pageSize <= 0 {
+		pageSize = backend.DefaultPageSize
+	}
+
+	var items []backend.Item
+	if prefix != "" && pageToken == "" {
+		items = append(items, backend.UpItem(bucketName, prefix))
+	}
+
+	// Collect entries in lexical order like a delimited object listing
+	keys := make([]string, 0, len(bkt.objects))
+	for name := range bkt.objects {
+		if strings.HasPrefix(name, prefix) && name != prefix {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	directories := make(map[string]bool)
+	entries := 0
+	for _, name := range keys {
+		// Resume after the previous page, which may have ended on a directory
+		if pageToken != "" && (name <= pageToken || strings.HasSuffix(pageToken, "/") && strings.HasPrefix(name, pageToken)) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(name, prefix)
+		entry := name
+		if i := strings.Index(rest, "/"); i >= 0 {
+			entry = prefix + rest[:i+1]
+			if directories[entry] {
+				continue
+			}
+		}
+
+		if entries == pageSize {
+			return items, pageToken, nil
+		}
+		entries++
+		pageToken = entry
+
+		if entry != name {
+			directories[entry] = true
+			items = append(items, backend.Item{
+				Name:      strings.TrimSuffix(strings.TrimPrefix(entry, prefix), "/"),
+				Path:      entry,
+				FullPath:  path.Join(bucketName, entry),
+				IsDir:     true,
+				ParentDir: prefix,
+			})
+			continue
+		}
+
+		obj := bkt.objects[name]
+		items = append(items, backend.Item{
+			Name:      rest,
+			Path:      name,
+			FullPath:  path.Join(bucketName, name),
+			Size:      int64(len(obj.Data)),
+			Updated:   obj.Updated,
+			ParentDir: prefix,
+		})
+	}
+
+	return items, "", nil
+}
+
+// ListRecursive lists every object below prefix
+func (b *Backend) ListRecursive(ctx context.Context, bucketName, prefix string) ([]backend.Item, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bkt, ok := b.buckets[bucketName]
+	if !ok {
+		return nil, fmt.Errorf("error listing objects: bucket %q not found", bucketName)
+	}
+
+	keys := make([]string, 0, len(bkt.objects))
+	for name := range bkt.objects {
+		if strings.HasPrefix(name, prefix) {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	items := make([]backend.Item, 0, len(keys))
+	for _, name := range keys {
+		obj := bkt.objects[name]
+		items = append(items, backend.Item{
+			Name:      path.Base(name),
+			Path:      name,
+			FullPath:  path.Join(bucketName, name),
+			Size:      int64(len(obj.Data)),
+			Updated:   obj.Updated,
+			ParentDir: backend.ParentPrefix(name),
+		})
+	}
+
+	return items, nil
+}
+
+// NewReader opens a reader for an object's data, reporting its CRC32C and
+// MD5 like a real store would
+func (b *Backend) NewReader(ctx context.Context, bucketName, objectName string) (io.ReadCloser, backend.Attrs, error) {
+	return b.NewRangeReader(ctx, bucketName, objectName, 0, -1)
+}
+
+// NewRangeReader opens a reader for a range of an object's data
+func (b *Backend) NewRangeReader(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, backend.Attrs, error) {
+	obj, ok := b.Object(bucketName, objectName)
+	if !ok {
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %s/%s not found", bucketName, objectName)
+	}
+
+	data := obj.Data[min(offset, int64(len(obj.Data))):]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+
+	sum := md5.Sum(obj.Data)
+	return io.NopCloser(bytes.NewReader(data)), backend.Attrs{
+		Size:            int64(len(obj.Data)),
+		ContentType:     obj.ContentType,
+		ContentEncoding: obj.ContentEncoding,
+		Generation:      strconv.FormatInt(obj.Generation, 10),
+		CRC32C:          crc32.Checksum(obj.Data, crc32.MakeTable(crc32.Castagnoli)),
+		HasCRC32C:       true,
+		MD5:             sum[:],
+	}, nil
+}
+
+// Upload stores the contents of r as an object
+func (b *Backend) Upload(ctx context.Context, bucketName, objectName string, r io.Reader, size int64) error {
+	b.mu.RLock()
+	_, ok := b.buckets[bucketName]
+	b.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("error uploading object: bucket %q not found", bucketName)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.PutObject(bucketName, objectName, Object{
+		Data:        data,
+		ContentType: mime.TypeByExtension(path.Ext(objectName)),
+	})
+	return nil
+}
+
+// Delete removes an object
+func (b *Backend) Delete(ctx context.Context, bucketName, objectName string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bkt, ok := b.buckets[bucketName]
+	if !ok {
+		return fmt.Errorf("error deleting %s: bucket %q not found", objectName, bucketName)
+	}
+	if _, ok := bkt.objects[objectName]; !ok {
+		return fmt.Errorf("error deleting %s: object not found", objectName)
+	}
+	delete(bkt.objects, objectName)
+	return nil
+}
+
+// Copy copies an object
+func (b *Backend) Copy(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	src, ok := b.buckets[srcBucket]
+	if !ok {
+		return fmt.Errorf("error copying %s: bucket %q not found", srcObject, srcBucket)
+	}
+	obj, ok := src.objects[srcObject]
+	if !ok {
+		return fmt.Errorf("error copying %s: object not found", srcObject)
+	}
+	dst, ok := b.buckets[dstBucket]
+	if !ok {
+		return fmt.Errorf("error copying %s: bucket %q not found", srcObject, dstBucket)
+	}
+
+	obj.Updated = time.Now()
+	b.generation++
+	obj.Generation = b.generation
+	dst.objects[dstObject] = obj
+	return nil
+}
+
+// Move moves an object. An object moved onto itself is left in place.
+func (b *Backend) Move(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	if srcBucket == dstBucket && srcObject == dstObject {
+		return nil
+	}
+	if err := b.Copy(ctx, srcBucket, srcObject, dstBucket, dstObject); err != nil {
+		return err
+	}
+	return b.Delete(ctx, srcBucket, srcObject)
+}
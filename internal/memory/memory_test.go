@@ -0,0 +1,333 @@
+package memory
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+)
+
+// seeded returns a store holding a bucket with the given objects, each
+// containing its own name
+func seeded(names ...string) *Backend {
+	b := New()
+	b.CreateBucket("bucket", time.Time{})
+	for _, name := range names {
+		b.PutObject("bucket", name, Object{Data: []byte(name)})
+	}
+	return b
+}
+
+// names returns the names of items
+func names(items []backend.Item) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.IsDir && item.Name != ".." {
+			out = append(out, item.Name+"/")
+			continue
+		}
+		out = append(out, item.Name)
+	}
+	return out
+}
+
+// objects returns the names of every object in the bucket
+func objects(t *testing.T, b *Backend) []string {
+	t.Helper()
+	items, err := b.ListRecursive(context.Background(), "bucket", "")
+	if err != nil {
+		t.Fatalf("ListRecursive: %v", err)
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, item.Path)
+	}
+	return out
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		want    map[string]Object
+		wantErr string
+	}{
+		{
+			name: "content and data",
+			fixture: `{"buckets": [{"name": "my-bucket", "objects": [
+				{"name": "logs/app.log", "content": "hello\n", "contentType": "text/plain"},
+				{"name": "bin/blob", "data": "AAECAw==", "metadata": {"owner": "ci"}}
+			]}]}`,
+			want: map[string]Object{
+				"logs/app.log": {Data: []byte("hello\n"), ContentType: "text/plain"},
+				"bin/blob":     {Data: []byte{0, 1, 2, 3}, Metadata: map[string]string{"owner": "ci"}},
+			},
+		},
+		{
+			name:    "empty bucket",
+			fixture: `{"buckets": [{"name": "my-bucket"}]}`,
+			want:    map[string]Object{},
+		},
+		{
+			name:    "invalid json",
+			fixture: `{"buckets": [`,
+			wantErr: "error parsing fixture",
+		},
+		{
+			name:    "invalid data",
+			fixture: `{"buckets": [{"name": "my-bucket", "objects": [{"name": "x", "data": "not base64!"}]}]}`,
+			wantErr: "error parsing fixture",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixturePath := filepath.Join(t.TempDir(), "fixture.json")
+			if err := os.WriteFile(fixturePath, []byte(tt.fixture), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := Load(fixturePath)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			buckets, err := b.ListBuckets(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(buckets); !reflect.DeepEqual(got, []string{"my-bucket/"}) {
+				t.Errorf("buckets = %v, want [my-bucket/]", got)
+			}
+			for name, want := range tt.want {
+				got, ok := b.Object("my-bucket", name)
+				if !ok {
+					t.Errorf("object %s not loaded", name)
+					continue
+				}
+				if string(got.Data) != string(want.Data) || got.ContentType != want.ContentType ||
+					!reflect.DeepEqual(got.Metadata, want.Metadata) {
+					t.Errorf("object %s = %+v, want %+v", name, got, want)
+				}
+				if got.Updated.IsZero() || got.Generation == 0 {
+					t.Errorf("object %s has no update time or generation", name)
+				}
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "error reading fixture") {
+		t.Errorf("Load() of a missing file error = %v", err)
+	}
+}
+
+func TestListObjectsPages(t *testing.T) {
+	b := seeded("a.txt", "b/1", "b/2", "c.txt", "d/e/f", "d/g", "d/h/i", "z")
+
+	tests := []struct {
+		name     string
+		prefix   string
+		pageSize int
+		want     [][]string
+	}{
+		{
+			name:     "single page",
+			pageSize: 100,
+			want:     [][]string{{"a.txt", "b/", "c.txt", "d/", "z"}},
+		},
+		{
+			name:     "pages of two",
+			pageSize: 2,
+			want:     [][]string{{"a.txt", "b/"}, {"c.txt", "d/"}, {"z"}},
+		},
+		{
+			name:     "pages of one",
+			pageSize: 1,
+			want:     [][]string{{"a.txt"}, {"b/"}, {"c.txt"}, {"d/"}, {"z"}},
+		},
+		{
+			name:     "prefix with up entry on the first page only",
+			prefix:   "d",
+			pageSize: 1,
+			want:     [][]string{{"..", "e/"}, {"g"}, {"h/"}},
+		},
+		{
+			name:     "prefix with trailing slash",
+			prefix:   "d/",
+			pageSize: 2,
+			want:     [][]string{{"..", "e/", "g"}, {"h/"}},
+		},
+		{
+			name:     "default page size",
+			prefix:   "b/",
+			pageSize: 0,
+			want:     [][]string{{"..", "1", "2"}},
+		},
+		{
+			name:     "prefix without objects",
+			prefix:   "missing/",
+			pageSize: 10,
+			want:     [][]string{{".."}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages [][]string
+			token := ""
+			for {
+				items, next, err := b.ListObjects(context.Background(), "bucket", tt.prefix, token, tt.pageSize)
+				if err != nil {
+					t.Fatalf("ListObjects: %v", err)
+				}
+				pages = append(pages, names(items))
+				if next == "" {
+					break
+				}
+				if len(pages) > 10 {
+					t.Fatalf("listing does not end, pages so far: %v", pages)
+				}
+				token = next
+			}
+			if !reflect.DeepEqual(pages, tt.want) {
+				t.Errorf("pages = %v, want %v", pages, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := b.ListObjects(context.Background(), "missing", "", "", 10); err == nil {
+		t.Error("ListObjects of a missing bucket succeeded")
+	}
+}
+
+func TestCopyMoveDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		op      func(context.Context, *Backend) error
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "copy",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Copy(ctx, "bucket", "a", "bucket", "dir/a")
+			},
+			want: []string{"a", "b", "dir/a"},
+		},
+		{
+			name: "copy over an existing object",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Copy(ctx, "bucket", "a", "bucket", "b")
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "copy a missing object",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Copy(ctx, "bucket", "missing", "bucket", "c")
+			},
+			want:    []string{"a", "b"},
+			wantErr: "object not found",
+		},
+		{
+			name: "copy to a missing bucket",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Copy(ctx, "bucket", "a", "missing", "a")
+			},
+			want:    []string{"a", "b"},
+			wantErr: `bucket "missing" not found`,
+		},
+		{
+			name: "move",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Move(ctx, "bucket", "a", "bucket", "dir/c")
+			},
+			want: []string{"b", "dir/c"},
+		},
+		{
+			name: "move onto itself",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Move(ctx, "bucket", "a", "bucket", "a")
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "move a missing object",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Move(ctx, "bucket", "missing", "bucket", "c")
+			},
+			want:    []string{"a", "b"},
+			wantErr: "object not found",
+		},
+		{
+			name: "delete",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Delete(ctx, "bucket", "a")
+			},
+			want: []string{"b"},
+		},
+		{
+			name: "delete a missing object",
+			op: func(ctx context.Context, b *Backend) error {
+				return b.Delete(ctx, "bucket", "missing")
+			},
+			want:    []string{"a", "b"},
+			wantErr: "object not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := seeded("a", "b")
+			err := tt.op(context.Background(), b)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+
+			if got := objects(t, b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("objects = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyKeepsContent(t *testing.T) {
+	b := seeded("a")
+	before, _ := b.Object("bucket", "a")
+	if err := b.Copy(context.Background(), "bucket", "a", "bucket", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, attrs, err := b.NewReader(context.Background(), "bucket", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a" {
+		t.Errorf("copied data = %q, want %q", data, "a")
+	}
+	if attrs.Generation == strconv.FormatInt(before.Generation, 10) {
+		t.Errorf("copy kept generation %s of its source", attrs.Generation)
+	}
+}
@@ -0,0 +1,365 @@
+// Package s3 implements a backend for Amazon S3 and S3-compatible object
+// stores such as MinIO, Cloudflare R2 and Ceph.
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// DefaultEndpoint is the endpoint used when none is configured
+const DefaultEndpoint = "s3.amazonaws.com"
+
+// Client represents an S3 client
+type Client struct {
+	client *minio.Client
+}
+
+// Client implements backend.Backend
+var _ backend.Backend = (*Client)(nil)
+
+// Config configures how to reach an S3-compatible service
+type Config struct {
+	// Endpoint is a host[:port] or URL such as http://localhost:9000.
+	// Plain hosts use HTTPS.
+	Endpoint string
+
+	// Region is the bucket region, detected automatically when empty
+	Region string
+}
+
+// NewClient creates a new S3 client. Credentials are looked up in the usual
+// AWS places: environment variables, the shared credentials file and the
+// instance metadata service. MinIO client environment variables are honoured
+// as well.
+func NewClient(cfg Config) (*Client, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	secure := true
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 endpoint %q: %v", endpoint, err)
+		}
+		secure = u.Scheme != "http"
+		endpoint = u.Host
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: secure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %v", err)
+	}
+
+	return &Client{
+		client: client,
+	}, nil
+}
+
+// Close closes the S3 client
+func (c *Client) Close() error {
+	return nil
+}
+
+// URL returns the s3:// URL of an object
+func (c *Client) URL(bucketName, objectName string) string {
+	return fmt.Sprintf("s3://%s/%s", bucketName, objectName)
+}
+
+// ListBuckets lists all buckets owned by the account
+func (c *Client) ListBuckets(ctx context.Context) ([]backend.Item, error) {
+	buckets, err := c.client.ListBuckets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing buckets: %v", err)
+	}
+
+	items := make([]backend.Item, 0, len(buckets))
+	for _, b := range buckets {
+		items = append(items, backend.Item{
+			Name:     b.Name,
+			Path:     b.Name,
+			FullPath: b.Name,
+			Updated:  b.CreationDate,
+			IsDir:    true,
+			IsBucket: true,
+		})
+	}
+
+	return items, nil
+}
+
+// ListObjects lists one page of objects in a bucket with the given prefix.
+// Common prefixes are returned as directories.
+func (c *Client) ListObjects(ctx context.Context, bucketName, prefix, pageToken string, pageSize int) ([]backend.Item, string, error) {
+	var items []backend.Item
+
+	prefix = backend.NormalizePrefix(prefix)
+
+	// Add a special item to navigate up a directory on the first page
+	if prefix != "" && pageToken == "" {
+		items = append(items, backend.UpItem(bucketName, prefix))
+	}
+
+	// The core listing call does not take a context, so honour
+	// cancellation around it
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	core := minio.Core{Client: c.client}
+	result, err := core.ListObjectsV2(bucketName, prefix, "", pageToken, "/", pageSize)
+	if err != nil {
+		return nil, "", fmt.Errorf("error listing objects: %v", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	// Merge directories and objects back into lexical order
+	var entries []backend.Item
+	for _, p := range result.CommonPrefixes {
+		entries = append(entries, backend.Item{
+			Name:      path.Base(p.Prefix),
+			Path:      p.Prefix,
+			FullPath:  path.Join(bucketName, p.Prefix),
+			IsDir:     true,
+			ParentDir: prefix,
+		})
+	}
+	for _, obj := range result.Contents {
+		// Skip the placeholder object some tools create for folders
+		if obj.Key == prefix {
+			continue
+		}
+
+		entries = append(entries, backend.Item{
+			Name:      path.Base(obj.Key),
+			Path:      obj.Key,
+			FullPath:  path.Join(bucketName, obj.Key),
+			Size:      obj.Size,
+			Updated:   obj.LastModified,
+			ParentDir: prefix,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	items = append(items, entries...)
+
+	nextPageToken := ""
+	if result.IsTruncated {
+		nextPageToken = result.NextContinuationToken
+	}
+
+	return items, nextPageToken, nil
+}
+
+// ListRecursive lists every object below prefix
+func (c *Client) ListRecursive(ctx context.Context, bucketName, prefix string) ([]backend.Item, error) {
+	var items []backend.Item
+
+	for obj := range c.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("error listing objects: %v", obj.Err)
+		}
+
+		items = append(items, backend.Item{
+			Name:      path.Base(obj.Key),
+			Path:      obj.Key,
+			FullPath:  path.Join(bucketName, obj.Key),
+			Size:      obj.Size,
+			Updated:   obj.LastModified,
+			ParentDir: backend.ParentPrefix(obj.Key),
+		})
+	}
+
+	return items, nil
+}
+
+// NewReader opens a streaming reader for an object
+func (c *Client) NewReader(ctx context.Context, bucketName, objectName string) (io.ReadCloser, backend.Attrs, error) {
+	obj, err := c.client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{Checksum: true})
+	if err != nil {
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+
+	// Stat sends the request, so errors such as a missing object show up here
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+
+	return obj, objectAttrs(info), nil
+}
+
+// NewRangeReader opens a streaming reader for a range of an object. Ranged
+// responses only describe the range, so the object is stat'ed first and
+// the read is pinned to the ETag that returned.
+func (c *Client) NewRangeReader(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, backend.Attrs, error) {
+	if offset == 0 && length < 0 {
+		return c.NewReader(ctx, bucketName, objectName)
+	}
+
+	info, err := c.client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+	if length == 0 || offset >= info.Size {
+		return io.NopCloser(strings.NewReader("")), objectAttrs(info), nil
+	}
+
+	opts := minio.GetObjectOptions{}
+	end := int64(0)
+	if length > 0 {
+		end = min(offset+length, info.Size) - 1
+	}
+	if err := opts.SetRange(offset, end); err != nil {
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+	if err := opts.SetMatchETag(info.ETag); err != nil {
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+
+	obj, err := c.client.GetObject(ctx, bucketName, objectName, opts)
+	if err != nil {
+		return nil, backend.Attrs{}, fmt.Errorf("error opening object: %v", err)
+	}
+	return obj, objectAttrs(info), nil
+}
+
+// objectAttrs converts the info of a whole object into backend attributes.
+// Versioned buckets identify a generation by version ID, others by ETag.
+func objectAttrs(info minio.ObjectInfo) backend.Attrs {
+	attrs := backend.Attrs{
+		Size:            info.Size,
+		ContentType:     info.ContentType,
+		ContentEncoding: info.Metadata.Get("Content-Encoding"),
+		Generation:      info.VersionID,
+		MD5:             etagMD5(info),
+	}
+	if attrs.Generation == "" {
+		attrs.Generation = info.ETag
+	}
+	if sum, err := base64.StdEncoding.DecodeString(info.ChecksumCRC32C); err == nil && len(sum) == 4 {
+		attrs.CRC32C = binary.BigEndian.Uint32(sum)
+		attrs.HasCRC32C = true
+	}
+	return attrs
+}
+
+// etagMD5 returns the MD5 hash held in an object's ETag. Multipart uploads
+// and objects encrypted with KMS or customer keys have ETags that are not
+// the MD5 of their content.
+func etagMD5(info minio.ObjectInfo) []byte {
+	if info.Metadata.Get("X-Amz-Server-Side-Encryption") == "aws:kms" ||
+		info.Metadata.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" {
+		return nil
+	}
+
+	sum, err := hex.DecodeString(strings.Trim(info.ETag, `"`))
+	if err != nil || len(sum) != 16 {
+		return nil
+	}
+	return sum
+}
+
+// Upload uploads the contents of r as an object. Large objects are sent as
+// multipart uploads.
+func (c *Client) Upload(ctx context.Context, bucketName, objectName string, r io.Reader, size int64) error {
+	_, err := c.client.PutObject(ctx, bucketName, objectName, r, size, minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(path.Ext(objectName)),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading object: %v", err)
+	}
+	return nil
+}
+
+// Delete deletes an object
+func (c *Client) Delete(ctx context.Context, bucketName, objectName string) error {
+	if err := c.client.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("error deleting %s: %v", objectName, err)
+	}
+	return nil
+}
+
+// maxCopySize is the largest object a single copy request can copy
+const maxCopySize = 5 << 30
+
+// copiedHeaders are the headers of an object that are carried over when it
+// is composed from multipart copies, which do not keep them by themselves
+var copiedHeaders = []string{"Content-Type", "Content-Encoding", "Content-Disposition", "Content-Language", "Cache-Control"}
+
+// Copy copies an object server-side. Objects up to 5 GiB are copied with a
+// single request, which keeps their headers and ETag. Larger objects are
+// composed from multipart copies, with their headers and metadata set on
+// the copy explicitly.
+func (c *Client) Copy(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	info, err := c.client.StatObject(ctx, srcBucket, srcObject, minio.StatObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("error copying %s to %s: %v", srcObject, dstObject, err)
+	}
+
+	src := minio.CopySrcOptions{Bucket: srcBucket, Object: srcObject, MatchETag: info.ETag}
+	dst := minio.CopyDestOptions{Bucket: dstBucket, Object: dstObject}
+	if info.Size <= maxCopySize {
+		_, err = c.client.CopyObject(ctx, dst, src)
+	} else {
+		dst.ReplaceMetadata = true
+		dst.UserMetadata = map[string]string{}
+		for k, v := range info.UserMetadata {
+			dst.UserMetadata[k] = v
+		}
+		for _, h := range copiedHeaders {
+			if v := info.Metadata.Get(h); v != "" {
+				dst.UserMetadata[h] = v
+			}
+		}
+		_, err = c.client.ComposeObject(ctx, dst, src)
+	}
+	if err != nil {
+		return fmt.Errorf("error copying %s to %s: %v", srcObject, dstObject, err)
+	}
+	return nil
+}
+
+// Move moves an object by copying it and deleting the source. An object
+// moved onto itself is left alone rather than deleted after the copy.
+func (c *Client) Move(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string) error {
+	if srcBucket == dstBucket && srcObject == dstObject {
+		return nil
+	}
+	if err := c.Copy(ctx, srcBucket, srcObject, dstBucket, dstObject); err != nil {
+		return err
+	}
+	return c.Delete(ctx, srcBucket, srcObject)
+}
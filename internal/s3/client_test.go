@@ -0,0 +1,514 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/minio/minio-go/v7"
+)
+
+// fakeObject is an object stored by fakeS3
+type fakeObject struct {
+	data   []byte
+	etag   string
+	header http.Header
+}
+
+// fakeS3 serves the parts of the S3 API the client uses for listing and
+// reading from an in-memory bucket, and records the requests it gets
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]fakeObject
+	requests []*http.Request
+}
+
+// put stores an object whose ETag is the MD5 of data, like a single part
+// upload would
+func (f *fakeS3) put(key string, data []byte, header http.Header) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sum := md5.Sum(data)
+	f.objects[key] = fakeObject{data: data, etag: hex.EncodeToString(sum[:]), header: header}
+}
+
+// requested returns the requests made so far
+func (f *fakeS3) requested() []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*http.Request(nil), f.requests...)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.mu.Unlock()
+
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" {
+		f.list(w, r)
+		return
+	}
+	if source := r.Header.Get("X-Amz-Copy-Source"); r.Method == http.MethodPut && source != "" {
+		f.copy(w, r, key, source)
+		return
+	}
+
+	f.mu.Lock()
+	obj, ok := f.objects[key]
+	f.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	for k, v := range obj.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", `"`+obj.etag+`"`)
+	http.ServeContent(w, r, key, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(obj.data))
+}
+
+// copy answers a CopyObject request, which keeps the headers of the
+// source object
+func (f *fakeS3) copy(w http.ResponseWriter, r *http.Request, key, source string) {
+	source, _ = url.PathUnescape(source)
+	_, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[srcKey]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if match := r.Header.Get("X-Amz-Copy-Source-If-Match"); match != "" && strings.Trim(match, `"`) != obj.etag {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	f.objects[key] = obj
+	fmt.Fprintf(w, `<CopyObjectResult><ETag>"%s"</ETag><LastModified>2024-01-01T00:00:00.000Z</LastModified></CopyObjectResult>`, obj.etag)
+}
+
+type listResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []listObject   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type listObject struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// list answers a ListObjectsV2 request. Like S3 it returns objects and
+// common prefixes in separate lists, and its continuation token is opaque.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	maxKeys, err := strconv.Atoi(q.Get("max-keys"))
+	if err != nil {
+		maxKeys = 1000
+	}
+	after := ""
+	if token := q.Get("continuation-token"); token != "" {
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			http.Error(w, "invalid continuation token", http.StatusBadRequest)
+			return
+		}
+		after = string(decoded)
+	}
+
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := listResult{Prefix: prefix, Delimiter: delimiter, MaxKeys: maxKeys}
+	last := ""
+	for _, key := range keys {
+		entry, isPrefix := key, false
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			entry, isPrefix = key[:len(prefix)+i+len(delimiter)], true
+		}
+		if entry <= after || entry == last {
+			continue
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+			break
+		}
+		result.KeyCount++
+		last = entry
+
+		if isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: entry})
+			continue
+		}
+		result.Contents = append(result.Contents, listObject{
+			Key:          key,
+			LastModified: "2024-01-01T00:00:00.000Z",
+			ETag:         `"` + f.objects[key].etag + `"`,
+			Size:         len(f.objects[key].data),
+		})
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// newTestClient returns a client talking to a fake S3 holding keys, each
+// containing its own name
+func newTestClient(t *testing.T, keys ...string) (*Client, *fakeS3) {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	fake := &fakeS3{objects: make(map[string]fakeObject)}
+	for _, key := range keys {
+		fake.put(key, []byte(key), nil)
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(Config{Endpoint: srv.URL, Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, fake
+}
+
+// names returns the names of items, with a slash after directories
+func names(items []backend.Item) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.IsDir && item.Name != ".." {
+			out = append(out, item.Name+"/")
+			continue
+		}
+		out = append(out, item.Name)
+	}
+	return out
+}
+
+func TestListObjects(t *testing.T) {
+	keys := []string{"a.txt", "b/1", "b/2", "b.txt", "c/", "c/x", "c/sub/y", "d/e/f", "z"}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		pageSize int
+		want     [][]string
+	}{
+		{
+			name:     "directories and objects merged in order",
+			pageSize: 1000,
+			want:     [][]string{{"a.txt", "b.txt", "b/", "c/", "d/", "z"}},
+		},
+		{
+			name:     "continuation tokens",
+			pageSize: 2,
+			want:     [][]string{{"a.txt", "b.txt"}, {"b/", "c/"}, {"d/", "z"}},
+		},
+		{
+			name:     "placeholder key skipped",
+			prefix:   "c",
+			pageSize: 1000,
+			want:     [][]string{{"..", "sub/", "x"}},
+		},
+		{
+			name:     "placeholder key skipped across pages",
+			prefix:   "c/",
+			pageSize: 1,
+			want:     [][]string{{".."}, {"sub/"}, {"x"}},
+		},
+		{
+			name:     "nested prefix",
+			prefix:   "d/e/",
+			pageSize: 1000,
+			want:     [][]string{{"..", "f"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newTestClient(t, keys...)
+
+			var pages [][]string
+			var tokens []string
+			token := ""
+			for {
+				items, next, err := c.ListObjects(context.Background(), "bucket", tt.prefix, token, tt.pageSize)
+				if err != nil {
+					t.Fatalf("ListObjects: %v", err)
+				}
+				pages = append(pages, names(items))
+				if next == "" {
+					break
+				}
+				if len(pages) > 10 {
+					t.Fatalf("listing does not end, pages so far: %v", pages)
+				}
+				tokens = append(tokens, next)
+				token = next
+			}
+			if !reflect.DeepEqual(pages, tt.want) {
+				t.Errorf("pages = %v, want %v", pages, tt.want)
+			}
+
+			// Each page after the first continues from the token of the last
+			requests := fake.requested()
+			for i, r := range requests {
+				want := ""
+				if i > 0 {
+					want = tokens[i-1]
+				}
+				if got := r.URL.Query().Get("continuation-token"); got != want {
+					t.Errorf("request %d continuation-token = %q, want %q", i, got, want)
+				}
+				if got := r.URL.Query().Get("delimiter"); got != "/" {
+					t.Errorf("request %d delimiter = %q, want /", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestListObjectsItems(t *testing.T) {
+	c, _ := newTestClient(t, "logs/2024/app.log", "logs/readme")
+
+	items, _, err := c.ListObjects(context.Background(), "bucket", "logs", "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []backend.Item{
+		backend.UpItem("bucket", "logs/"),
+		{Name: "2024", Path: "logs/2024/", FullPath: "bucket/logs/2024", IsDir: true, ParentDir: "logs/"},
+		{Name: "readme", Path: "logs/readme", FullPath: "bucket/logs/readme", Size: 11, ParentDir: "logs/"},
+	}
+	for i := range items {
+		items[i].Updated = time.Time{}
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %+v, want %+v", items, want)
+	}
+}
+
+func TestNewRangeReader(t *testing.T) {
+	const key = "data.bin"
+	data := []byte("0123456789")
+
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+		want   string
+		gets   int
+	}{
+		{name: "range", offset: 2, length: 3, want: "234", gets: 1},
+		{name: "to the end", offset: 7, length: -1, want: "789", gets: 1},
+		{name: "past the end", offset: 8, length: 100, want: "89", gets: 1},
+		{name: "offset at size", offset: 10, length: 5, want: "", gets: 0},
+		{name: "offset past size", offset: 20, length: -1, want: "", gets: 0},
+		{name: "empty range", offset: 3, length: 0, want: "", gets: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fake := newTestClient(t)
+			fake.put(key, data, nil)
+
+			reader, attrs, err := c.NewRangeReader(context.Background(), "bucket", key, tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("NewRangeReader: %v", err)
+			}
+			got, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+			if attrs.Size != int64(len(data)) {
+				t.Errorf("attrs.Size = %d, want the size of the whole object %d", attrs.Size, len(data))
+			}
+
+			// Ranged reads are pinned to the ETag the object was stat'ed at
+			etag := `"` + fake.objects[key].etag + `"`
+			gets := 0
+			for _, r := range fake.requested() {
+				if r.Method != http.MethodGet {
+					continue
+				}
+				gets++
+				if got := r.Header.Get("If-Match"); got != etag {
+					t.Errorf("If-Match = %q, want %q", got, etag)
+				}
+			}
+			if gets != tt.gets {
+				t.Errorf("sent %d GET requests, want %d", gets, tt.gets)
+			}
+		})
+	}
+}
+
+func TestNewRangeReaderChangedObject(t *testing.T) {
+	c, fake := newTestClient(t)
+	fake.put("data.bin", []byte("0123456789"), nil)
+
+	reader, _, err := c.NewRangeReader(context.Background(), "bucket", "data.bin", 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	// The object is replaced between the stat and the read
+	fake.put("data.bin", []byte("abcdefghij"), nil)
+	if got, err := io.ReadAll(reader); err == nil {
+		t.Errorf("read %q of a replaced object, want an error", got)
+	}
+}
+
+func TestMoveOntoItself(t *testing.T) {
+	c, fake := newTestClient(t, "a")
+	if err := c.Move(context.Background(), "bucket", "a", "bucket", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fake.requested()); n != 0 {
+		t.Errorf("moving an object onto itself sent %d requests", n)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	c, fake := newTestClient(t)
+	header := http.Header{"Content-Type": {"image/jpeg"}, "Content-Encoding": {"gzip"}, "Cache-Control": {"no-cache"}}
+	fake.put("photos/a.jpg", []byte("jpeg data"), header)
+
+	if err := c.Copy(context.Background(), "bucket", "photos/a.jpg", "bucket", "archive/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	var methods []string
+	for _, r := range fake.requested() {
+		methods = append(methods, r.Method)
+	}
+	if want := []string{http.MethodHead, http.MethodPut}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("requests = %v, want a stat and a single copy %v", methods, want)
+	}
+
+	reader, attrs, err := c.NewReader(context.Background(), "bucket", "archive/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if attrs.ContentType != "image/jpeg" || attrs.ContentEncoding != "gzip" {
+		t.Errorf("copy has Content-Type %q and Content-Encoding %q, want image/jpeg and gzip", attrs.ContentType, attrs.ContentEncoding)
+	}
+	if attrs.MD5 == nil {
+		t.Error("copy has a multipart ETag")
+	}
+	if err := c.Copy(context.Background(), "bucket", "missing", "bucket", "b"); err == nil {
+		t.Error("copying a missing object succeeded")
+	}
+}
+
+func TestEtagMD5(t *testing.T) {
+	data := []byte("hello")
+	sum := md5.Sum(data)
+	plain := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name   string
+		etag   string
+		header http.Header
+		want   []byte
+	}{
+		{name: "single part", etag: plain, want: sum[:]},
+		{name: "quoted", etag: `"` + plain + `"`, want: sum[:]},
+		{name: "multipart", etag: plain + "-3"},
+		{name: "empty"},
+		{name: "not hex", etag: "xyz"},
+		{
+			name:   "SSE-S3",
+			etag:   plain,
+			header: http.Header{"X-Amz-Server-Side-Encryption": {"AES256"}},
+			want:   sum[:],
+		},
+		{
+			name:   "SSE-KMS",
+			etag:   plain,
+			header: http.Header{"X-Amz-Server-Side-Encryption": {"aws:kms"}},
+		},
+		{
+			name:   "SSE-C",
+			etag:   plain,
+			header: http.Header{"X-Amz-Server-Side-Encryption-Customer-Algorithm": {"AES256"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := etagMD5(minio.ObjectInfo{ETag: tt.etag, Metadata: tt.header})
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("etagMD5() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderAttrs(t *testing.T) {
+	c, fake := newTestClient(t)
+	fake.put("plain", []byte("hello"), nil)
+	fake.put("kms", []byte("hello"), http.Header{"X-Amz-Server-Side-Encryption": {"aws:kms"}})
+	sum := md5.Sum([]byte("hello"))
+
+	for key, want := range map[string][]byte{"plain": sum[:], "kms": nil} {
+		reader, attrs, err := c.NewReader(context.Background(), "bucket", key)
+		if err != nil {
+			t.Fatalf("NewReader(%s): %v", key, err)
+		}
+		reader.Close()
+		if !bytes.Equal(attrs.MD5, want) {
+			t.Errorf("%s: attrs.MD5 = %x, want %x", key, attrs.MD5, want)
+		}
+		if attrs.Generation != fake.objects[key].etag {
+			t.Errorf("%s: attrs.Generation = %q, want the ETag %q", key, attrs.Generation, fake.objects[key].etag)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+// Package transfer runs downloads, uploads and copies in the background.
+//
+// A Manager keeps a queue of transfers and runs a bounded number of them at
+// a time. Transfers report their progress as they run and can be paused,
+// resumed, cancelled and retried. The UI polls the manager for snapshots
+// rather than being notified, so the manager never blocks on the UI.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State is the lifecycle state of a transfer
+type State int
+
+const (
+	// Queued transfers wait for a free worker
+	Queued State = iota
+	// Running transfers are in progress
+	Running
+	// Paused transfers are stopped until they are resumed
+	Paused
+	// Done transfers finished successfully
+	Done
+	// Failed transfers stopped with an error and can be retried
+	Failed
+	// Cancelled transfers were stopped by the user and can be retried
+	Cancelled
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case Queued:
+		return "queued"
+	case Running:
+		return "running"
+	case Paused:
+		return "paused"
+	case Done:
+		return "done"
+	case Failed:
+		return "failed"
+	case Cancelled:
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+// Finished reports whether the transfer has stopped for good unless it is
+// retried
+func (s State) Finished() bool {
+	return s == Done || s == Failed || s == Cancelled
+}
+
+// Kind describes what a transfer does
+type Kind string
+
+const (
+	// Download saves an object to a local file
+	Download Kind = "download"
+	// Upload stores a local file as an object
+	Upload Kind = "upload"
+	// Copy copies an object, server-side where the backend supports it
+	Copy Kind = "copy"
+	// Move copies an object and deletes the source
+	Move Kind = "move"
+)
+
+// Func performs a transfer, reporting the bytes moved to p. It must return
+// promptly once ctx is cancelled. Paused and retried transfers are
+// restarted by calling Func again with a fresh Progress, so it must be
+// safe to repeat; it may pick up where the previous call stopped.
+type Func func(ctx context.Context, p *Progress) error
+
+// Progress is handed to a running transfer to report its progress
+type Progress struct {
+	bytes atomic.Int64
+
+	mu   sync.Mutex
+	note string
+}
+
+// Write counts the bytes written, so that a Progress can be used with
+// io.TeeReader and io.MultiWriter
+func (p *Progress) Write(b []byte) (int, error) {
+	p.bytes.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Add adds n bytes to the progress, e.g. for data a transfer skipped
+func (p *Progress) Add(n int64) {
+	p.bytes.Add(n)
+}
+
+// SetNote sets a short remark shown with the transfer, such as whether its
+// checksum was verified
+func (p *Progress) SetNote(note string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.note = note
+}
+
+func (p *Progress) getNote() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.note
+}
+
+// Transfer is a single queued transfer
+type Transfer struct {
+	id   int
+	kind Kind
+	name string
+	dest string
+	size int64
+	fn   Func
+
+	state    State
+	err      error
+	progress *Progress
+	started  time.Time
+	finished time.Time
+	cancel   context.CancelFunc
+
+	// active is set while fn runs, which may outlast a pause or cancel
+	active bool
+}
+
+// Snapshot is a point-in-time copy of a transfer's state
+type Snapshot struct {
+	ID      int
+	Kind    Kind
+	Name    string
+	Dest    string
+	Size    int64
+	Bytes   int64
+	State   State
+	Err     error
+	Note    string
+	Elapsed time.Duration
+}
+
+// Rate returns the transfer's throughput in bytes per second
+func (s Snapshot) Rate() int64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(s.Bytes) / s.Elapsed.Seconds())
+}
+
+// Stats summarises the transfers of a manager
+type Stats struct {
+	Queued    int
+	Running   int
+	Paused    int
+	Done      int
+	Failed    int
+	Cancelled int
+
+	// Bytes and Size total the unfinished transfers
+	Bytes int64
+	Size  int64
+
+	// Rate is the combined throughput of the running transfers
+	Rate int64
+}
+
+// Active returns the number of transfers that have not finished
+func (s Stats) Active() int {
+	return s.Queued + s.Running + s.Paused
+}
+
+// Manager queues transfers and runs up to a fixed number of them at once
+type Manager struct {
+	mu        sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	workers   int
+	running   int
+	nextID    int
+	transfers []*Transfer
+	finished  []Snapshot
+
+	// closed is set once Close was called, after which nothing starts
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// ErrNotFound is returned for operations on unknown transfers
+var ErrNotFound = errors.New("transfer not found")
+
+// NewManager creates a manager that runs up to workers transfers at once
+func NewManager(workers int) *Manager {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{ctx: ctx, cancel: cancel, workers: workers}
+}
+
+// Add queues a transfer and returns its ID. Dest is the path the transfer
+// writes to and size its expected number of bytes, or 0 if unknown.
+func (m *Manager) Add(kind Kind, name, dest string, size int64, fn Func) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	m.transfers = append(m.transfers, &Transfer{
+		id:       m.nextID,
+		kind:     kind,
+		name:     name,
+		dest:     dest,
+		size:     size,
+		fn:       fn,
+		state:    Queued,
+		progress: &Progress{},
+	})
+	m.schedule()
+	return m.nextID
+}
+
+// schedule starts queued transfers while workers are free. The caller must
+// hold m.mu.
+func (m *Manager) schedule() {
+	if m.closed {
+		return
+	}
+	for _, t := range m.transfers {
+		if m.running >= m.workers {
+			return
+		}
+		if t.state != Queued || t.active {
+			continue
+		}
+		m.start(t)
+	}
+}
+
+// start runs a transfer in the background. The caller must hold m.mu.
+func (m *Manager) start(t *Transfer) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	t.state = Running
+	t.err = nil
+	t.cancel = cancel
+	t.active = true
+	t.started = time.Now()
+	t.progress = &Progress{}
+	m.running++
+	m.wg.Add(1)
+
+	go func() {
+		defer m.wg.Done()
+		err := t.fn(ctx, t.progress)
+		cancel()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		t.active = false
+		m.running--
+
+		// Pausing and cancelling set the state before stopping fn
+		if t.state == Running {
+			t.finished = time.Now()
+			t.err = err
+			t.state = Done
+			if err != nil {
+				t.state = Failed
+			}
+			m.finished = append(m.finished, t.snapshot())
+		}
+		m.schedule()
+	}()
+}
+
+// find returns the transfer with the given ID. The caller must hold m.mu.
+func (m *Manager) find(id int) *Transfer {
+	for _, t := range m.transfers {
+		if t.id == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// Pause stops a queued or running transfer until it is resumed
+func (m *Manager) Pause(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.find(id)
+	if t == nil {
+		return ErrNotFound
+	}
+	if t.state != Queued && t.state != Running {
+		return errors.New("only queued and running transfers can be paused")
+	}
+	if t.state == Running {
+		t.cancel()
+	}
+	t.state = Paused
+	return nil
+}
+
+// Resume queues a paused transfer again
+func (m *Manager) Resume(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.find(id)
+	if t == nil {
+		return ErrNotFound
+	}
+	if t.state != Paused {
+		return errors.New("only paused transfers can be resumed")
+	}
+	t.state = Queued
+	m.schedule()
+	return nil
+}
+
+// Cancel stops a transfer that has not finished
+func (m *Manager) Cancel(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.find(id)
+	if t == nil {
+		return ErrNotFound
+	}
+	if t.state.Finished() {
+		return errors.New("transfer has already finished")
+	}
+	if t.state == Running {
+		t.cancel()
+	}
+	t.state = Cancelled
+	t.err = context.Canceled
+	t.finished = time.Now()
+	m.finished = append(m.finished, t.snapshot())
+	return nil
+}
+
+// Retry queues a failed or cancelled transfer again
+func (m *Manager) Retry(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.find(id)
+	if t == nil {
+		return ErrNotFound
+	}
+	if t.state != Failed && t.state != Cancelled {
+		return errors.New("only failed and cancelled transfers can be retried")
+	}
+	t.state = Queued
+	t.err = nil
+	m.schedule()
+	return nil
+}
+
+// ClearFinished forgets transfers that are done, failed or cancelled
+func (m *Manager) ClearFinished() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.transfers[:0]
+	for _, t := range m.transfers {
+		if !t.state.Finished() {
+			kept = append(kept, t)
+		}
+	}
+	m.transfers = kept
+}
+
+// CancelAll cancels every transfer that has not finished
+func (m *Manager) CancelAll() {
+	m.mu.Lock()
+	ids := make([]int, 0, len(m.transfers))
+	for _, t := range m.transfers {
+		if !t.state.Finished() {
+			ids = append(ids, t.id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		m.Cancel(id)
+	}
+}
+
+// Close cancels every transfer that has not finished and waits for the
+// running ones to return. No transfer starts after the manager is closed.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	for _, t := range m.transfers {
+		if !t.state.Finished() {
+			t.state = Cancelled
+			t.err = context.Canceled
+			t.finished = time.Now()
+			m.finished = append(m.finished, t.snapshot())
+		}
+	}
+	m.mu.Unlock()
+
+	m.cancel()
+	m.wg.Wait()
+}
+
+// List returns snapshots of every transfer in the order they were added
+func (m *Manager) List() []Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshots := make([]Snapshot, len(m.transfers))
+	for i, t := range m.transfers {
+		snapshots[i] = t.snapshot()
+	}
+	return snapshots
+}
+
+// Stats summarises the manager's transfers
+func (m *Manager) Stats() Stats {
+	var s Stats
+	for _, snap := range m.List() {
+		switch snap.State {
+		case Queued:
+			s.Queued++
+		case Running:
+			s.Running++
+			s.Rate += snap.Rate()
+		case Paused:
+			s.Paused++
+		case Done:
+			s.Done++
+		case Failed:
+			s.Failed++
+		case Cancelled:
+			s.Cancelled++
+		}
+		if !snap.State.Finished() {
+			s.Bytes += snap.Bytes
+			s.Size += snap.Size
+		}
+	}
+	return s
+}
+
+// TakeFinished returns the transfers that finished, failed or were
+// cancelled since the last call
+func (m *Manager) TakeFinished() []Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	finished := m.finished
+	m.finished = nil
+	return finished
+}
+
+// snapshot copies the transfer's state. The caller must hold m.mu.
+func (t *Transfer) snapshot() Snapshot {
+	s := Snapshot{
+		ID:    t.id,
+		Kind:  t.kind,
+		Name:  t.name,
+		Dest:  t.dest,
+		Size:  t.size,
+		Bytes: t.progress.bytes.Load(),
+		State: t.state,
+		Err:   t.err,
+		Note:  t.progress.getNote(),
+	}
+
+	switch {
+	case t.started.IsZero():
+	case t.state == Running:
+		s.Elapsed = time.Since(t.started)
+	case t.finished.After(t.started):
+		s.Elapsed = t.finished.Sub(t.started)
+	}
+	return s
+}
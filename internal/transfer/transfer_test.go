@@ -0,0 +1,302 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	m := NewManager(1)
+
+	started := make(chan struct{})
+	var returned atomic.Bool
+	m.Add(Download, "running", "dest", 0, func(ctx context.Context, p *Progress) error {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		returned.Store(true)
+		return ctx.Err()
+	})
+	var ran atomic.Bool
+	queued := m.Add(Upload, "queued", "dest", 0, func(ctx context.Context, p *Progress) error {
+		ran.Store(true)
+		return nil
+	})
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first transfer did not start")
+	}
+	m.Close()
+
+	if !returned.Load() {
+		t.Error("Close returned before the running transfer did")
+	}
+	if ran.Load() {
+		t.Error("a queued transfer started after Close")
+	}
+	for _, s := range m.List() {
+		if s.State != Cancelled || !errors.Is(s.Err, context.Canceled) {
+			t.Errorf("transfer %d is %v with error %v after Close, want cancelled", s.ID, s.State, s.Err)
+		}
+	}
+	if got := len(m.TakeFinished()); got != 2 {
+		t.Errorf("%d finished transfers reported, want 2", got)
+	}
+
+	if err := m.Retry(queued); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if ran.Load() {
+		t.Error("a retried transfer started after Close")
+	}
+}
+
+// blocking is a transfer func that runs until it is released or stopped,
+// counting its runs and how many run at once
+type blocking struct {
+	release chan struct{}
+	runs    atomic.Int32
+	current atomic.Int32
+	most    atomic.Int32
+}
+
+func newBlocking() *blocking {
+	return &blocking{release: make(chan struct{})}
+}
+
+func (b *blocking) run(ctx context.Context, p *Progress) error {
+	b.runs.Add(1)
+	n := b.current.Add(1)
+	defer b.current.Add(-1)
+	for {
+		most := b.most.Load()
+		if n <= most || b.most.CompareAndSwap(most, n) {
+			break
+		}
+	}
+
+	select {
+	case <-b.release:
+		p.Add(10)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitState waits until the transfer with the given ID is in state
+func waitState(t *testing.T, m *Manager, id int, state State) Snapshot {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, s := range m.List() {
+			if s.ID == id && s.State == state {
+				return s
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("transfer %d did not become %v: %+v", id, state, m.List())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitRuns waits until transfer funcs were called n times
+func waitRuns(t *testing.T, b *blocking, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.runs.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("transfer funcs ran %d times, want %d", b.runs.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitIdle waits until no transfer func is running
+func waitIdle(t *testing.T, b *blocking) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.current.Load() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("transfer funcs did not return")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWorkerLimit(t *testing.T) {
+	m := NewManager(2)
+	defer m.Close()
+
+	b := newBlocking()
+	var ids []int
+	for range 5 {
+		ids = append(ids, m.Add(Download, "file", "dest", 10, b.run))
+	}
+	waitRuns(t, b, 2)
+	time.Sleep(10 * time.Millisecond)
+	if s := m.Stats(); s.Running != 2 || s.Queued != 3 {
+		t.Errorf("%d running and %d queued, want 2 and 3", s.Running, s.Queued)
+	}
+
+	close(b.release)
+	for _, id := range ids {
+		waitState(t, m, id, Done)
+	}
+	if most := b.most.Load(); most != 2 {
+		t.Errorf("%d transfers ran at once, want 2", most)
+	}
+	if got := len(m.TakeFinished()); got != 5 {
+		t.Errorf("%d finished transfers reported, want 5", got)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+
+	b := newBlocking()
+	running := m.Add(Download, "running", "dest", 10, b.run)
+	queued := m.Add(Download, "queued", "dest", 10, b.run)
+	waitRuns(t, b, 1)
+
+	// Pausing the running transfer frees its worker for the queued one
+	if err := m.Pause(running); err != nil {
+		t.Fatal(err)
+	}
+	waitRuns(t, b, 2)
+	if err := m.Pause(queued); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, b)
+	if s := m.Stats(); s.Paused != 2 {
+		t.Errorf("%d paused transfers, want 2", s.Paused)
+	}
+	if got := m.TakeFinished(); len(got) != 0 {
+		t.Errorf("paused transfers are reported finished: %+v", got)
+	}
+	if err := m.Pause(running); err == nil {
+		t.Error("a paused transfer was paused again")
+	}
+
+	// Resuming runs the func again
+	if err := m.Resume(running); err != nil {
+		t.Fatal(err)
+	}
+	waitRuns(t, b, 3)
+	close(b.release)
+	waitState(t, m, running, Done)
+	if err := m.Resume(running); err == nil {
+		t.Error("a finished transfer was resumed")
+	}
+	if got := b.runs.Load(); got != 3 {
+		t.Errorf("transfer funcs ran %d times, want 3", got)
+	}
+	if s := waitState(t, m, queued, Paused); s.Bytes != 0 {
+		t.Errorf("paused transfer reports %d bytes", s.Bytes)
+	}
+}
+
+func TestCancelAndRetry(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+
+	b := newBlocking()
+	running := m.Add(Download, "running", "dest", 10, b.run)
+	queued := m.Add(Download, "queued", "dest", 10, b.run)
+	waitRuns(t, b, 1)
+
+	for _, id := range []int{running, queued} {
+		if err := m.Cancel(id); err != nil {
+			t.Fatal(err)
+		}
+		if s := waitState(t, m, id, Cancelled); !errors.Is(s.Err, context.Canceled) {
+			t.Errorf("cancelled transfer %d has error %v", id, s.Err)
+		}
+	}
+	waitIdle(t, b)
+	if got := b.runs.Load(); got != 1 {
+		t.Errorf("transfer funcs ran %d times, want only the running one", got)
+	}
+	if got := len(m.TakeFinished()); got != 2 {
+		t.Errorf("%d finished transfers reported, want 2", got)
+	}
+	if err := m.Cancel(running); err == nil {
+		t.Error("a cancelled transfer was cancelled again")
+	}
+
+	close(b.release)
+	if err := m.Retry(queued); err != nil {
+		t.Fatal(err)
+	}
+	if s := waitState(t, m, queued, Done); s.Err != nil || s.Bytes != 10 {
+		t.Errorf("retried transfer has error %v and %d bytes", s.Err, s.Bytes)
+	}
+	if err := m.Retry(queued); err == nil {
+		t.Error("a finished transfer was retried")
+	}
+	if err := m.Retry(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("retrying an unknown transfer returned %v", err)
+	}
+}
+
+func TestRetryFailed(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+
+	var attempts atomic.Int32
+	id := m.Add(Upload, "flaky", "dest", 0, func(ctx context.Context, p *Progress) error {
+		if attempts.Add(1) == 1 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	if s := waitState(t, m, id, Failed); s.Err == nil || s.Err.Error() != "connection reset" {
+		t.Errorf("failed transfer has error %v", s.Err)
+	}
+	if err := m.Retry(id); err != nil {
+		t.Fatal(err)
+	}
+	if s := waitState(t, m, id, Done); s.Err != nil {
+		t.Errorf("retried transfer has error %v", s.Err)
+	}
+}
+
+func TestClearFinished(t *testing.T) {
+	m := NewManager(1)
+	defer m.Close()
+
+	b := newBlocking()
+	failed := m.Add(Upload, "failed", "dest", 0, func(ctx context.Context, p *Progress) error {
+		return errors.New("denied")
+	})
+	waitState(t, m, failed, Failed)
+	done := m.Add(Upload, "done", "dest", 0, func(ctx context.Context, p *Progress) error { return nil })
+	waitState(t, m, done, Done)
+	cancelled := m.Add(Download, "cancelled", "dest", 10, b.run)
+	running := m.Add(Download, "running", "dest", 10, b.run)
+	paused := m.Add(Download, "paused", "dest", 10, b.run)
+	if err := m.Pause(paused); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Cancel(cancelled); err != nil {
+		t.Fatal(err)
+	}
+	waitRuns(t, b, 1)
+
+	m.ClearFinished()
+	var kept []int
+	for _, s := range m.List() {
+		kept = append(kept, s.ID)
+	}
+	if len(kept) != 2 || kept[0] != running || kept[1] != paused {
+		t.Errorf("kept transfers %v, want %v", kept, []int{running, paused})
+	}
+}
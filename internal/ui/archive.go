@@ -0,0 +1,514 @@
+package ui
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+)
+
+// archiveFormat returns the format of an archive object from its name,
+// "zip" or "tar", or "" if it is not an archive. Tarballs may be
+// compressed with any of the known codecs.
+func archiveFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".jar"):
+		return "zip"
+	case strings.HasSuffix(strings.ToLower(decompressedName(name)), ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// memberName normalises the name of an archive entry into a relative path
+// without trailing slash. Names that try to leave the archive, such as
+// "../x", are kept inside it.
+func memberName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// archive is an archive object browsed as a virtual directory. Its index
+// is read when it is first listed and kept while it is browsed.
+type archive struct {
+	item   backend.Item
+	format string
+
+	mu      sync.Mutex
+	members []archiveMember
+	loaded  bool
+}
+
+// archiveMember is a file or directory in an archive
+type archiveMember struct {
+	name     string
+	size     int64
+	modified time.Time
+	isDir    bool
+
+	// offset is where the data of a member of an uncompressed tarball
+	// starts, or -1 if the archive must be read up to the member
+	offset int64
+
+	// zip holds where a member of a zip archive is stored, as read from
+	// the central directory
+	zip zipEntry
+}
+
+// zipEntry is where and how a member of a zip archive is stored
+type zipEntry struct {
+	header     int64
+	compressed int64
+	method     uint16
+	crc32      uint32
+}
+
+// newArchive creates an archive for an object whose index is not read yet
+func newArchive(item backend.Item) *archive {
+	return &archive{item: item, format: archiveFormat(item.Name)}
+}
+
+// contains reports whether fullPath is the archive or a directory in it
+func (a *archive) contains(fullPath string) bool {
+	return fullPath == a.item.FullPath || strings.HasPrefix(fullPath, a.item.FullPath+"/")
+}
+
+// bucketName returns the bucket holding the archive object
+func (a *archive) bucketName() string {
+	bucketName, _ := backend.ParsePath(a.item.FullPath)
+	return bucketName
+}
+
+// compressed reports whether the archive is a compressed tarball, which
+// can only be read from the start
+func (a *archive) compressed() bool {
+	return a.format == "tar" && decompressedName(a.item.Name) != a.item.Name
+}
+
+// index reads the list of members once. Zip archives read their central
+// directory and uncompressed tarballs their headers with ranged reads,
+// skipping the data in between; compressed tarballs are read in full.
+func (a *archive) index(ctx context.Context, b backend.Backend) ([]archiveMember, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.loaded {
+		return a.members, nil
+	}
+
+	var members []archiveMember
+	var err error
+	switch {
+	case a.format == "zip":
+		members, err = a.indexZip(ctx, b)
+	case a.compressed():
+		members, err = a.indexTarStream(ctx, b)
+	default:
+		r := backend.NewRangeReaderAt(ctx, b, a.bucketName(), a.item.Path, a.item.Size)
+		members, err = readTarIndex(io.NewSectionReader(r, 0, a.item.Size), true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive %s: %v", a.item.Name, err)
+	}
+
+	a.members = withParents(members)
+	a.loaded = true
+	return a.members, nil
+}
+
+// indexZip lists the members of a zip archive from its central directory
+func (a *archive) indexZip(ctx context.Context, b backend.Backend) ([]archiveMember, error) {
+	probe := &headerProbe{r: backend.NewRangeReaderAt(ctx, b, a.bucketName(), a.item.Path, a.item.Size)}
+	zr, err := zip.NewReader(probe, a.item.Size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return nil, err
+	}
+
+	// The central directory is read; from here on the offsets of the local
+	// headers are only recorded so that open can read the members directly
+	probe.probing = true
+	members := make([]archiveMember, 0, len(zr.File))
+	for _, f := range zr.File {
+		name := memberName(f.Name)
+		if name == "" || !f.Mode().IsRegular() && !f.Mode().IsDir() {
+			continue
+		}
+		probe.offset = -1
+		f.DataOffset()
+		if probe.offset < 0 {
+			return nil, fmt.Errorf("no local header for %s", f.Name)
+		}
+		members = append(members, archiveMember{
+			name:     name,
+			size:     int64(f.UncompressedSize64),
+			modified: f.Modified,
+			isDir:    f.Mode().IsDir(),
+			offset:   -1,
+			zip: zipEntry{
+				header:     probe.offset,
+				compressed: int64(f.CompressedSize64),
+				method:     f.Method,
+				crc32:      f.CRC32,
+			},
+		})
+	}
+	return members, nil
+}
+
+// errProbed is returned by a probing headerProbe
+var errProbed = errors.New("offset probed")
+
+// headerProbe reads through to r until probing is set. From then on it
+// records the offset of each read instead of reading, which reveals where
+// zip.File.DataOffset looks for a member's local header.
+type headerProbe struct {
+	r       io.ReaderAt
+	probing bool
+	offset  int64
+}
+
+// ReadAt implements io.ReaderAt
+func (p *headerProbe) ReadAt(b []byte, off int64) (int, error) {
+	if p.probing {
+		p.offset = off
+		return 0, errProbed
+	}
+	return p.r.ReadAt(b, off)
+}
+
+// indexTarStream lists the members of a compressed tarball by reading it
+// from the start
+func (a *archive) indexTarStream(ctx context.Context, b backend.Backend) ([]archiveMember, error) {
+	reader, attrs, err := b.NewReader(ctx, a.bucketName(), a.item.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, _, err := decompressReader(reader, a.item.Name, attrs.ContentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	defer decompressed.Close()
+	return readTarIndex(decompressed, false)
+}
+
+// readTarIndex lists the members of a tarball. With seekable set, r is an
+// io.SectionReader and the data offsets of the members are recorded.
+func readTarIndex(r io.Reader, seekable bool) ([]archiveMember, error) {
+	tr := tar.NewReader(r)
+	var members []archiveMember
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return members, nil
+		}
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			return nil, err
+		}
+
+		name := memberName(hdr.Name)
+		if name == "" || hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir {
+			continue
+		}
+		member := archiveMember{
+			name:     name,
+			size:     hdr.Size,
+			modified: hdr.ModTime,
+			isDir:    hdr.Typeflag == tar.TypeDir,
+			offset:   -1,
+		}
+		if seekable {
+			member.offset, _ = r.(io.Seeker).Seek(0, io.SeekCurrent)
+		}
+		members = append(members, member)
+	}
+}
+
+// withParents adds the directories that members are in but that the
+// archive has no entries for, and sorts the members by name. Of members
+// stored more than once, such as files appended to a tarball again, the
+// last one wins like it does when the archive is extracted.
+func withParents(members []archiveMember) []archiveMember {
+	dirs := make(map[string]bool)
+	for _, member := range members {
+		if member.isDir {
+			dirs[member.name] = true
+		}
+	}
+	for _, member := range members {
+		for dir := path.Dir(member.name); dir != "."; dir = path.Dir(dir) {
+			if dirs[dir] {
+				break
+			}
+			dirs[dir] = true
+			members = append(members, archiveMember{name: dir, isDir: true, offset: -1})
+		}
+	}
+
+	sort.SliceStable(members, func(i, j int) bool { return members[i].name < members[j].name })
+	unique := members[:0]
+	for i, member := range members {
+		if i+1 < len(members) && members[i+1].name == member.name {
+			continue
+		}
+		unique = append(unique, member)
+	}
+	return unique
+}
+
+// list returns the members directly inside the directory at fullPath,
+// preceded by the ".." entry that leads back out
+func (a *archive) list(ctx context.Context, b backend.Backend, fullPath string) ([]backend.Item, error) {
+	members, err := a.index(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := strings.Trim(strings.TrimPrefix(fullPath, a.item.FullPath), "/")
+	items := []backend.Item{{Name: "..", FullPath: path.Dir(fullPath), IsDir: true}}
+	for _, member := range members {
+		parent := path.Dir(member.name)
+		if parent == "." {
+			parent = ""
+		}
+		if parent != dir {
+			continue
+		}
+		items = append(items, a.memberItem(member))
+	}
+	return items, nil
+}
+
+// memberItem returns the list item of a member. Its path is the member's
+// name within the archive and its full path leads through the archive.
+func (a *archive) memberItem(member archiveMember) backend.Item {
+	return backend.Item{
+		Name:      path.Base(member.name),
+		Path:      member.name,
+		FullPath:  a.item.FullPath + "/" + member.name,
+		Size:      member.size,
+		Updated:   member.modified,
+		IsDir:     member.isDir,
+		ParentDir: path.Dir(member.name),
+	}
+}
+
+// below returns the files at or below the member at name
+func (a *archive) below(name string) []archiveMember {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var files []archiveMember
+	for _, member := range a.members {
+		if !member.isDir && (member.name == name || strings.HasPrefix(member.name, name+"/")) {
+			files = append(files, member)
+		}
+	}
+	return files
+}
+
+// downloads maps items in the archive onto the local files they download
+// to. Directories expand to every file below them, like folders do in
+// expandDownloads.
+func (a *archive) downloads(items []backend.Item) []downloadTarget {
+	var targets []downloadTarget
+	for _, item := range items {
+		for _, member := range a.below(item.Path) {
+			rel := strings.TrimPrefix(strings.TrimPrefix(member.name, item.Path), "/")
+			targets = append(targets, downloadTarget{a.memberItem(member), filepath.Join(item.Name, filepath.FromSlash(rel))})
+		}
+	}
+	return targets
+}
+
+// find returns the member named name
+func (a *archive) find(name string) (archiveMember, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := sort.Search(len(a.members), func(i int) bool { return a.members[i].name >= name })
+	if i < len(a.members) && a.members[i].name == name {
+		return a.members[i], true
+	}
+	return archiveMember{}, false
+}
+
+// open opens a file in the archive for reading. Members of zip archives
+// are found with the central directory read by index and, like members of
+// uncompressed tarballs, read with ranged reads of their data only;
+// compressed tarballs are read from the start up to the member. The
+// returned attributes hold the member's size.
+func (a *archive) open(ctx context.Context, b backend.Backend, name string) (io.ReadCloser, backend.Attrs, error) {
+	member, ok := a.find(name)
+	if !ok || member.isDir {
+		return nil, backend.Attrs{}, fmt.Errorf("%s is not a file in %s", name, a.item.Name)
+	}
+	attrs := backend.Attrs{Size: member.size}
+
+	switch {
+	case a.format == "zip":
+		reader, err := a.openZip(ctx, b, member)
+		if err != nil {
+			return nil, attrs, fmt.Errorf("error reading %s from %s: %v", name, a.item.Name, err)
+		}
+		return reader, attrs, nil
+	case member.offset >= 0:
+		reader, _, err := b.NewRangeReader(ctx, a.bucketName(), a.item.Path, member.offset, member.size)
+		return reader, attrs, err
+	}
+
+	reader, archiveAttrs, err := b.NewReader(ctx, a.bucketName(), a.item.Path)
+	if err != nil {
+		return nil, attrs, err
+	}
+	decompressed, _, err := decompressReader(reader, a.item.Name, archiveAttrs.ContentEncoding)
+	if err != nil {
+		reader.Close()
+		return nil, attrs, err
+	}
+
+	tr := tar.NewReader(decompressed)
+	for {
+		hdr, err := tr.Next()
+		if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			decompressed.Close()
+			reader.Close()
+			if err == io.EOF {
+				return nil, attrs, fmt.Errorf("%s is no longer in %s", name, a.item.Name)
+			}
+			return nil, attrs, fmt.Errorf("error reading archive %s: %v", a.item.Name, err)
+		}
+		if memberName(hdr.Name) == name && hdr.Typeflag == tar.TypeReg {
+			return tarMember{tr, decompressed, reader}, attrs, nil
+		}
+	}
+}
+
+// zipLocalHeaderLen is the size of the fixed part of a zip local file
+// header, which is followed by the member's name and extra field
+const zipLocalHeaderLen = 30
+
+// openZip opens a member of a zip archive where the central directory
+// read by index says it is, reading its local header and then its data
+// with ranged reads
+func (a *archive) openZip(ctx context.Context, b backend.Backend, member archiveMember) (io.ReadCloser, error) {
+	entry := member.zip
+	header, _, err := b.NewRangeReader(ctx, a.bucketName(), a.item.Path, entry.header, zipLocalHeaderLen)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, zipLocalHeaderLen)
+	_, err = io.ReadFull(header, buf)
+	header.Close()
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(buf) != 0x04034b50 {
+		return nil, zip.ErrFormat
+	}
+	start := entry.header + zipLocalHeaderLen + int64(binary.LittleEndian.Uint16(buf[26:])) + int64(binary.LittleEndian.Uint16(buf[28:]))
+
+	var decompress func(io.Reader) io.ReadCloser
+	switch entry.method {
+	case zip.Store:
+		decompress = io.NopCloser
+	case zip.Deflate:
+		decompress = flate.NewReader
+	default:
+		return nil, zip.ErrAlgorithm
+	}
+
+	data, _, err := b.NewRangeReader(ctx, a.bucketName(), a.item.Path, start, entry.compressed)
+	if err != nil {
+		return nil, err
+	}
+	return &zipMember{
+		ReadCloser: decompress(data),
+		data:       data,
+		hash:       crc32.NewIEEE(),
+		crc32:      entry.crc32,
+		remaining:  member.size,
+	}, nil
+}
+
+// zipMember reads and checks the data of a member of a zip archive,
+// closing the ranged read when it is closed
+type zipMember struct {
+	io.ReadCloser
+	data      io.Closer
+	hash      hash.Hash32
+	crc32     uint32
+	remaining int64
+}
+
+// Read implements io.Reader, failing at the end of the member if its size
+// or checksum differ from the central directory's
+func (z *zipMember) Read(p []byte) (int, error) {
+	n, err := z.ReadCloser.Read(p)
+	z.hash.Write(p[:n])
+	z.remaining -= int64(n)
+	if z.remaining < 0 {
+		return n, zip.ErrFormat
+	}
+	if err == io.EOF {
+		if z.remaining > 0 {
+			return n, io.ErrUnexpectedEOF
+		}
+		if z.hash.Sum32() != z.crc32 {
+			return n, zip.ErrChecksum
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (z *zipMember) Close() error {
+	z.ReadCloser.Close()
+	return z.data.Close()
+}
+
+// tarMember reads a member of a tarball that was read up to it, closing
+// the readers of the archive when it is closed
+type tarMember struct {
+	*tar.Reader
+	decompressed io.Closer
+	reader       io.Closer
+}
+
+// Close implements io.Closer
+func (t tarMember) Close() error {
+	t.decompressed.Close()
+	return t.reader.Close()
+}
+
+// memberSource describes the archive a viewed member was read from
+type memberSource struct {
+	archive string
+	size    int64
+
+	// shown is the number of bytes shown when truncated is set
+	shown     int64
+	truncated bool
+}
+
+// describe summarises where the member came from for the status bar
+func (s *memberSource) describe() string {
+	if s.truncated {
+		return fmt.Sprintf("[in %s, showing the first %s of %s]", s.archive, formatSize(s.shown), formatSize(s.size))
+	}
+	return fmt.Sprintf("[in %s]", s.archive)
+}
@@ -0,0 +1,213 @@
+package ui
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+)
+
+// archiveFiles are the files of the test archives, in the order stored
+var archiveFiles = []struct {
+	name string
+	data string
+}{
+	{"notes.txt", "top level\n"},
+	{"docs/readme.md", "# readme\n"},
+	{"docs/guide/intro.txt", "hello from the guide\n"},
+}
+
+// padding returns incompressible data that pushes the zip central
+// directory several chunks away from the members before it
+func padding() []byte {
+	data := make([]byte, 7<<19)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// zipArchive returns a zip archive of archiveFiles followed by a large
+// stored member
+func zipArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range archiveFiles {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f.data))
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "padding.bin", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(padding())
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// tarArchive returns a tarball of archiveFiles
+func tarArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range archiveFiles {
+		hdr := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data)), ModTime: time.Unix(0, 0), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.data))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// gzipped returns data compressed with gzip
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// openArchive stores data as the archive called name in photos and
+// enters it
+func openArchive(t *testing.T, b *memory.Backend, name string, data []byte) Model {
+	t.Helper()
+	b.PutObject("photos", name, memory.Object{Data: data})
+	m := newTestModel(t, b, "photos")
+	m = pressAndRun(selectItem(t, m, name), "enter")
+	if m.archive == nil {
+		t.Fatalf("%s did not open as an archive, status %q", name, m.statusMsg)
+	}
+	return m
+}
+
+func TestBrowseArchive(t *testing.T) {
+	archives := map[string][]byte{
+		"bundle.zip":    zipArchive(t),
+		"bundle.tar":    tarArchive(t),
+		"bundle.tar.gz": gzipped(t, tarArchive(t)),
+	}
+	for name, data := range archives {
+		t.Run(name, func(t *testing.T) {
+			m := openArchive(t, testStore(), name, data)
+			want := []string{"..", "docs", "notes.txt"}
+			if name == "bundle.zip" {
+				want = append(want, "padding.bin")
+			}
+			if got := itemNames(m); !reflect.DeepEqual(got, want) {
+				t.Errorf("top level lists %v, want %v", got, want)
+			}
+
+			m = pressAndRun(selectItem(t, m, "docs"), "enter")
+			if got, want := itemNames(m), []string{"..", "guide", "readme.md"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("docs lists %v, want %v", got, want)
+			}
+
+			m = pressAndRun(selectItem(t, m, "guide"), "enter")
+			m = pressAndRun(selectItem(t, m, "intro.txt"), "v")
+			if !m.viewingFile {
+				t.Fatalf("intro.txt did not open, status %q", m.statusMsg)
+			}
+			if got, want := m.file.content, "hello from the guide\n"; got != want {
+				t.Errorf("intro.txt shows %q, want %q", got, want)
+			}
+			if m.file.member == nil {
+				t.Error("intro.txt is not described as an archive member")
+			}
+
+			// Back closes the viewer, then leaves guide, docs and the archive
+			for range 4 {
+				m = pressAndRun(m, "backspace")
+			}
+			if m.archive != nil || m.currentPath != "photos" {
+				t.Errorf("at %q with archive %v, want photos outside the archive", m.currentPath, m.archive != nil)
+			}
+		})
+	}
+}
+
+func TestViewZipMemberReadsOnlyItsData(t *testing.T) {
+	b := &countedRanges{Backend: testStore()}
+	data := zipArchive(t)
+	b.PutObject("photos", "bundle.zip", memory.Object{Data: data})
+	m := newTestModel(t, b, "photos")
+	m = pressAndRun(selectItem(t, m, "bundle.zip"), "enter")
+	if m.archive == nil {
+		t.Fatalf("bundle.zip did not open as an archive, status %q", m.statusMsg)
+	}
+
+	b.read.Store(0)
+	m = pressAndRun(selectItem(t, m, "notes.txt"), "v")
+	if got, want := m.file.content, "top level\n"; got != want {
+		t.Errorf("notes.txt shows %q, want %q", got, want)
+	}
+	if got := b.read.Load(); got > 1<<10 {
+		t.Errorf("viewing notes.txt read %d bytes, want its local header and data only", got)
+	}
+}
+
+func TestZipMemberChecksum(t *testing.T) {
+	data := zipArchive(t)
+	m := openArchive(t, testStore(), "bundle.zip", data)
+
+	// Claim a different checksum than the stored data has
+	for i, member := range m.archive.members {
+		if member.name == "notes.txt" {
+			m.archive.members[i].zip.crc32++
+		}
+	}
+	m = pressAndRun(selectItem(t, m, "notes.txt"), "v")
+	if m.viewingFile {
+		t.Errorf("a member with a wrong checksum opened with %q", m.file.content)
+	}
+}
+
+func TestDownloadArchiveMembers(t *testing.T) {
+	archives := map[string][]byte{
+		"bundle.zip":    zipArchive(t),
+		"bundle.tar":    tarArchive(t),
+		"bundle.tar.gz": gzipped(t, tarArchive(t)),
+	}
+	for name, data := range archives {
+		t.Run(name, func(t *testing.T) {
+			m := openArchive(t, testStore(), name, data)
+			dir := t.TempDir()
+			m.options.DownloadDir = dir
+
+			m = pressAndRun(selectItem(t, m, "docs"), "d")
+			waitTransfers(t, m)
+			for _, f := range archiveFiles[1:] {
+				path := filepath.Join(dir, filepath.FromSlash(f.name))
+				got, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != f.data {
+					t.Errorf("%s holds %q, want %q", f.name, got, f.data)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err == nil {
+				t.Error("notes.txt was downloaded with docs")
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// codec is a compression format that is decompressed on the fly
+type codec struct {
+	name       string
+	extensions []string
+	encodings  []string
+	magic      []byte
+	open       func(io.Reader) (io.ReadCloser, error)
+}
+
+// codecs are the compression formats that are recognised. Snappy is only
+// recognised in its framing format, since raw Snappy blocks cannot be
+// streamed.
+var codecs = []codec{
+	{
+		name:       "gzip",
+		extensions: []string{".gz", ".gzip", ".tgz"},
+		encodings:  []string{"gzip", "x-gzip"},
+		magic:      []byte{0x1f, 0x8b},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		name:       "zstd",
+		extensions: []string{".zst", ".zstd"},
+		encodings:  []string{"zstd"},
+		magic:      []byte{0x28, 0xb5, 0x2f, 0xfd},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return decoder.IOReadCloser(), nil
+		},
+	},
+	{
+		name:       "bzip2",
+		extensions: []string{".bz2", ".bzip2"},
+		encodings:  []string{"bzip2", "x-bzip2"},
+		magic:      []byte("BZh"),
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		name:       "snappy",
+		extensions: []string{".sz", ".snappy"},
+		encodings:  []string{"snappy", "x-snappy-framed"},
+		magic:      []byte("\xff\x06\x00\x00sNaPpY"),
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(snappy.NewReader(r)), nil
+		},
+	},
+}
+
+// sniffCodec returns the codec whose magic bytes head starts with, or nil
+func sniffCodec(head []byte) *codec {
+	for i, c := range codecs {
+		if !bytes.HasPrefix(head, c.magic) {
+			continue
+		}
+		// "BZh" alone is too common in text; a block header must follow
+		if c.name == "bzip2" && (len(head) < 10 || head[3] < '1' || head[3] > '9' || string(head[4:10]) != "1AY&SY") {
+			continue
+		}
+		return &codecs[i]
+	}
+	return nil
+}
+
+// claimedCodec returns the codec an object's extension or Content-Encoding
+// names, or nil. Its content may still not be compressed, e.g. when GCS
+// decompresses objects stored with Content-Encoding: gzip as they are read.
+func claimedCodec(name, contentEncoding string) *codec {
+	ext := strings.ToLower(path.Ext(name))
+	encoding, _, _ := mime.ParseMediaType(contentEncoding)
+	for i, c := range codecs {
+		for _, e := range c.extensions {
+			if ext == e {
+				return &codecs[i]
+			}
+		}
+		for _, e := range c.encodings {
+			if encoding == e {
+				return &codecs[i]
+			}
+		}
+	}
+	return nil
+}
+
+// decompressedName strips a compression extension from name, so that
+// "app.log.gz" is shown and saved as "app.log". Tarballs named ".tgz"
+// become ".tar".
+func decompressedName(name string) string {
+	ext := path.Ext(name)
+	c := claimedCodec(name, "")
+	switch {
+	case c == nil:
+		return name
+	case strings.EqualFold(ext, ".tgz"):
+		return strings.TrimSuffix(name, ext) + ".tar"
+	}
+	return strings.TrimSuffix(name, ext)
+}
+
+// probeSize is how much of content without magic bytes is decoded to
+// tell whether it is compressed the way its name claims
+const probeSize = 4 << 10
+
+// decompressReader wraps the content r of the object called name in a
+// decompressor if it starts with the magic bytes of a known codec.
+// Content without them is decompressed with the codec name claims, since
+// not every stream can be told by its first bytes, if its start decodes
+// that way. Other content is read as it is, with a nil codec. That
+// includes content stored with a Content-Encoding, which stores such as
+// GCS decode as they serve it, so that its name no longer tells.
+func decompressReader(r io.Reader, name, contentEncoding string) (io.ReadCloser, *codec, error) {
+	buffered := bufio.NewReaderSize(r, probeSize)
+	head, _ := buffered.Peek(16)
+	c := sniffCodec(head)
+	if c == nil {
+		if len(head) == 0 || claimedCodec("", contentEncoding) != nil {
+			return io.NopCloser(buffered), nil, nil
+		}
+		c = claimedCodec(name, "")
+		probe, _ := buffered.Peek(probeSize)
+		if c == nil || !c.decodes(probe) {
+			return io.NopCloser(buffered), nil, nil
+		}
+		decompressed, err := c.open(buffered)
+		if err != nil {
+			return io.NopCloser(buffered), nil, nil
+		}
+		return mismatchReader{decompressed, name, c}, c, nil
+	}
+
+	decompressed, err := c.open(buffered)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decompressing %s: %v", c.name, err)
+	}
+	return decompressed, c, nil
+}
+
+// decodes reports whether data, the start of some content, decodes with
+// the codec without an error other than ending early
+func (c *codec) decodes(data []byte) bool {
+	r, err := c.open(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+	_, err = io.Copy(io.Discard, r)
+	return err == nil || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// mismatchReader reports errors decompressing content whose compression
+// was only claimed by its name as a mismatch, for the rare content whose
+// start decoded but whose rest does not
+type mismatchReader struct {
+	io.ReadCloser
+	name  string
+	codec *codec
+}
+
+func (r mismatchReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		err = mismatchError(r.name, r.codec, err)
+	}
+	return n, err
+}
+
+// mismatchError is the error for the content of the object called name
+// not decompressing with the codec its name claims
+func mismatchError(name string, c *codec, err error) error {
+	return fmt.Errorf("%s is named as %s but is not %s compressed: %v", path.Base(name), c.name, c.name, err)
+}
+
+// compression describes how a viewed object was decompressed
+type compression struct {
+	codec *codec
+
+	// compressed is the size of the object and uncompressed the number
+	// of bytes it decompressed to, the bytes shown if truncated is set, or
+	// -1 if only the first rows of a table were decompressed
+	compressed   int64
+	uncompressed int64
+	truncated    bool
+
+	// transcoded is set when the object claims a compression, but the
+	// store already decompressed it as it was read
+	transcoded bool
+}
+
+// describe summarises the compression for the status bar
+func (c *compression) describe() string {
+	switch {
+	case c.transcoded && c.truncated:
+		return fmt.Sprintf("[%s, decompressed by the server, showing the first %s]", c.codec.name, formatSize(c.uncompressed))
+	case c.transcoded:
+		return fmt.Sprintf("[%s, decompressed by the server]", c.codec.name)
+	case c.uncompressed < 0:
+		return fmt.Sprintf("[%s: %s compressed]", c.codec.name, formatSize(c.compressed))
+	case c.truncated:
+		return fmt.Sprintf("[%s: %s compressed, showing the first %s uncompressed]",
+			c.codec.name, formatSize(c.compressed), formatSize(c.uncompressed))
+	}
+	return fmt.Sprintf("[%s: %s compressed, %s uncompressed]",
+		c.codec.name, formatSize(c.compressed), formatSize(c.uncompressed))
+}
@@ -0,0 +1,207 @@
+package ui
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+	"github.com/fernandoabolafio/lazybucket/internal/transfer"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressed returns data compressed with the named codec
+func compressed(t *testing.T, name string, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch name {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zstd":
+		enc, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = enc
+	case "snappy":
+		w = snappy.NewBufferedWriter(&buf)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// skippable returns a zstd skippable frame, which decoders ignore but
+// which hides the magic bytes of the frame that follows
+func skippable() []byte {
+	frame := binary.LittleEndian.AppendUint32(nil, 0x184d2a50)
+	frame = binary.LittleEndian.AppendUint32(frame, 4)
+	return append(frame, "meta"...)
+}
+
+func TestDecompressReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		object    string
+		encoding  string
+		content   []byte
+		wantCodec string
+		want      string
+		wantErr   string
+	}{
+		{
+			name:      "sniffed",
+			object:    "app.log",
+			content:   compressed(t, "gzip", "hello\n"),
+			wantCodec: "gzip",
+			want:      "hello\n",
+		},
+		{
+			name:      "sniffed snappy framing",
+			object:    "app.log.sz",
+			content:   compressed(t, "snappy", "hello\n"),
+			wantCodec: "snappy",
+			want:      "hello\n",
+		},
+		{
+			name:    "plain",
+			object:  "app.log",
+			content: []byte("hello\n"),
+			want:    "hello\n",
+		},
+		{
+			name:      "claimed by the extension",
+			object:    "app.log.zst",
+			content:   append(skippable(), compressed(t, "zstd", "hello\n")...),
+			wantCodec: "zstd",
+			want:      "hello\n",
+		},
+		{
+			name:    "empty object with an extension",
+			object:  "app.log.gz",
+			content: nil,
+			want:    "",
+		},
+		{
+			name:    "gzip extension on plain content",
+			object:  "logs/app.log.gz",
+			content: []byte("hello\n"),
+			want:    "hello\n",
+		},
+		{
+			name:    "short snappy object that is not snappy",
+			object:  "app.log.sz",
+			content: []byte("hi"),
+			want:    "hi",
+		},
+		{
+			name:     "gzip object the server decoded",
+			object:   "app.log.gz",
+			encoding: "gzip",
+			content:  []byte("hello\n"),
+			want:     "hello\n",
+		},
+		{
+			name:      "gzip object the server did not decode",
+			object:    "app.log.gz",
+			encoding:  "gzip",
+			content:   compressed(t, "gzip", "hello\n"),
+			wantCodec: "gzip",
+			want:      "hello\n",
+		},
+		{
+			name:    "corrupt after the sniffed magic bytes",
+			object:  "app.log",
+			content: []byte{0x1f, 0x8b, 0, 0},
+			wantErr: "error decompressing gzip",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, c, err := decompressReader(bytes.NewReader(tt.content), tt.object, tt.encoding)
+			var data []byte
+			if err == nil {
+				defer r.Close()
+				data, err = io.ReadAll(r)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			codecName := ""
+			if c != nil {
+				codecName = c.name
+			}
+			if codecName != tt.wantCodec {
+				t.Errorf("codec = %q, want %q", codecName, tt.wantCodec)
+			}
+			if string(data) != tt.want {
+				t.Errorf("content = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestViewServerDecodedObject(t *testing.T) {
+	b := testStore()
+	b.PutObject("photos", "app.log.gz", memory.Object{Data: []byte("hello\n"), ContentEncoding: "gzip"})
+	m := pressAndRun(selectItem(t, newTestModel(t, b, "photos"), "app.log.gz"), "v")
+	if !m.viewingFile {
+		t.Fatalf("app.log.gz did not open, status %q", m.statusMsg)
+	}
+	if m.file.content != "hello\n" {
+		t.Errorf("content = %q, want %q", m.file.content, "hello\n")
+	}
+	if c := m.file.compression; c == nil || !c.transcoded {
+		t.Errorf("compression = %+v, want it decompressed by the server", c)
+	}
+}
+
+func TestViewMislabelledObject(t *testing.T) {
+	b := testStore()
+	b.PutObject("photos", "notes.txt.gz", memory.Object{Data: []byte("not gzip\n")})
+	m := pressAndRun(selectItem(t, newTestModel(t, b, "photos"), "notes.txt.gz"), "v")
+	if !m.viewingFile {
+		t.Fatalf("notes.txt.gz did not open, status %q", m.statusMsg)
+	}
+	if m.file.content != "not gzip\n" || m.file.compression != nil {
+		t.Errorf("content = %q with compression %+v, want the raw bytes", m.file.content, m.file.compression)
+	}
+}
+
+func TestSaveServerDecodedObject(t *testing.T) {
+	dir := t.TempDir()
+	open := func(ctx context.Context) (io.ReadCloser, backend.Attrs, error) {
+		return io.NopCloser(strings.NewReader("hello\n")), backend.Attrs{Size: 6, ContentEncoding: "gzip"}, nil
+	}
+	if err := saveStream(context.Background(), "app.log.gz", open, dir, "app.log", ConflictOverwrite, true, &transfer.Progress{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello\n" {
+		t.Errorf("saved %q, want %q", data, "hello\n")
+	}
+}
@@ -0,0 +1,183 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/transfer"
+)
+
+// copyPair is a single object copy from a source to a destination
+type copyPair struct {
+	srcBucket string
+	srcObject string
+	dstBucket string
+	dstObject string
+	size      int64
+
+	// exists is set when an object is already stored at the destination
+	exists bool
+}
+
+// onItself reports whether the pair copies an object onto itself
+func (p copyPair) onItself() bool {
+	return p.srcBucket == p.dstBucket && p.srcObject == p.dstObject
+}
+
+// expandCopies maps item onto object copies whose names start with
+// dstPrefix+name. Folders and buckets expand to every object below them.
+// Copies onto objects that already exist are flagged.
+func expandCopies(ctx context.Context, b backend.Backend, item backend.Item, dstBucket, dstName string) ([]copyPair, error) {
+	srcBucket, srcObject := backend.ParsePath(item.FullPath)
+	if !item.IsDir {
+		pairs := []copyPair{{srcBucket: srcBucket, srcObject: item.Path, dstBucket: dstBucket, dstObject: dstName, size: item.Size}}
+		return markExisting(ctx, b, pairs, dstBucket, dstName)
+	}
+
+	srcPrefix := backend.NormalizePrefix(srcObject)
+	objects, err := b.ListRecursive(ctx, srcBucket, srcPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make([]copyPair, 0, len(objects))
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Path, srcPrefix)
+		pairs = append(pairs, copyPair{srcBucket: srcBucket, srcObject: obj.Path, dstBucket: dstBucket, dstObject: dstName + "/" + rel, size: obj.Size})
+	}
+	return markExisting(ctx, b, pairs, dstBucket, dstName)
+}
+
+// markExisting flags the pairs whose destination object already exists,
+// listing the objects whose names start with dstName once for all of them.
+// Copies onto themselves are skipped and so never conflict.
+func markExisting(ctx context.Context, b backend.Backend, pairs []copyPair, dstBucket, dstName string) ([]copyPair, error) {
+	existing, err := b.ListRecursive(ctx, dstBucket, dstName)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(existing))
+	for _, obj := range existing {
+		names[obj.Path] = true
+	}
+	for i, p := range pairs {
+		pairs[i].exists = names[p.dstObject] && !p.onItself()
+	}
+	return pairs, nil
+}
+
+// planPaste maps items onto copies into destPath
+func planPaste(ctx context.Context, b backend.Backend, items []backend.Item, destPath string) ([]copyPair, error) {
+	dstBucket, dstPrefix := backend.ParsePath(destPath)
+	dstPrefix = backend.NormalizePrefix(dstPrefix)
+
+	var pairs []copyPair
+	for _, item := range items {
+		expanded, err := expandCopies(ctx, b, item, dstBucket, dstPrefix+item.Name)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, expanded...)
+	}
+	return pairs, nil
+}
+
+// planRename maps item onto a new name in the same folder. Renaming a
+// folder moves every object below it.
+func planRename(ctx context.Context, b backend.Backend, item backend.Item, newName string) ([]copyPair, error) {
+	bucketName, objectName := backend.ParsePath(item.FullPath)
+	parent := backend.ParentPrefix(strings.TrimSuffix(objectName, "/"))
+	return expandCopies(ctx, b, item, bucketName, parent+newName)
+}
+
+// copyObjects lists the object copies, or moves when move is set, that
+// plan maps the marked items onto and counts the objects they would
+// replace. label names the operation in the conflict dialog.
+func (m Model) copyObjects(label string, plan func(context.Context) ([]copyPair, error), move bool) tea.Cmd {
+	ctx := m.planning.ctx
+	destPath := m.currentPath
+	return func() tea.Msg {
+		pairs, err := plan(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg{err}
+		}
+
+		msg := copyPlanMsg{label: label, path: destPath, pairs: pairs, move: move}
+		for _, p := range pairs {
+			if p.exists {
+				msg.conflicts++
+			}
+		}
+		return msg
+	}
+}
+
+// queueCopies queues a transfer for every planned copy or move. Copies
+// onto themselves are skipped so that a move never deletes its only copy,
+// and so are copies onto existing objects unless policy is overwrite.
+func (m Model) queueCopies(plan copyPlanMsg, policy ConflictPolicy) (Model, tea.Cmd) {
+	kind := transfer.Copy
+	if plan.move {
+		kind = transfer.Move
+	}
+
+	queued, skipped := 0, 0
+	for _, p := range plan.pairs {
+		if p.onItself() {
+			continue
+		}
+		if p.exists && policy != ConflictOverwrite {
+			skipped++
+			continue
+		}
+		queued++
+		m.queue.Add(kind, m.backend.URL(p.dstBucket, p.dstObject), plan.path, p.size,
+			func(ctx context.Context, progress *transfer.Progress) error {
+				var err error
+				if plan.move {
+					err = m.backend.Move(ctx, p.srcBucket, p.srcObject, p.dstBucket, p.dstObject)
+				} else {
+					err = m.backend.Copy(ctx, p.srcBucket, p.srcObject, p.dstBucket, p.dstObject)
+				}
+				if err == nil {
+					progress.Add(p.size)
+				}
+				return err
+			})
+	}
+
+	m.statusMsg = fmt.Sprintf("Queued %d %s transfers to %s", queued, kind, plan.path)
+	if skipped > 0 {
+		m.statusMsg += fmt.Sprintf(", skipped %d existing objects", skipped)
+	}
+	return m.watchTransfers()
+}
+
+// renderCopyConfirm renders the dialog asking what to do with the objects
+// a copy, move or rename would replace
+func (m Model) renderCopyConfirm() string {
+	plan := m.confirmCopy
+	body := fmt.Sprintf("%d of %d objects already exist in\n%s\n\n[o] overwrite   [s] skip   [n] cancel",
+		plan.conflicts, len(plan.pairs), plan.path)
+	if len(plan.pairs) == 1 {
+		p := plan.pairs[0]
+		body = fmt.Sprintf("%s already exists\n\n[o] overwrite   [n] cancel", m.backend.URL(p.dstBucket, p.dstObject))
+	}
+
+	return downloadModalStyle.Render(downloadModalTitleStyle.Render(plan.label) + "\n\n" + body)
+}
+
+type copyPlanMsg struct {
+	label     string
+	path      string
+	pairs     []copyPair
+	move      bool
+	conflicts int
+}
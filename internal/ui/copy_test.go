@@ -0,0 +1,169 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+)
+
+// copyStore returns a store whose folders photos/2024 and photos/archive
+// already hold some of the objects pasted into them
+func copyStore() *memory.Backend {
+	b := testStore()
+	b.PutObject("photos", "notes.txt", memory.Object{Data: []byte("notes")})
+	b.PutObject("photos", "2024/readme.txt", memory.Object{Data: []byte("old readme")})
+	b.PutObject("photos", "2024/jan/old.jpg", memory.Object{Data: []byte("old")})
+	b.PutObject("photos", "archive/2024/jan/a.jpg", memory.Object{Data: []byte("archived a")})
+	return b
+}
+
+// waitTransfers waits until the transfer queue is idle
+func waitTransfers(t *testing.T, m Model) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.queue.Stats().Active() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("transfers did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// content returns the data of an object in the photos bucket
+func content(t *testing.T, b *memory.Backend, name string) string {
+	t.Helper()
+	obj, ok := b.Object("photos", name)
+	if !ok {
+		t.Fatalf("photos/%s does not exist", name)
+	}
+	return string(obj.Data)
+}
+
+// paste yanks the item called name in photos and pastes it into the folder
+// dest, returning the model once the paste is planned
+func paste(t *testing.T, b *memory.Backend, name, dest, key string) Model {
+	t.Helper()
+	m := newTestModel(t, b, "photos")
+	m, _ = press(selectItem(t, m, name), "y")
+	m = pressAndRun(selectItem(t, m, dest), "enter")
+	return pressAndRun(m, key)
+}
+
+func TestPasteConflicts(t *testing.T) {
+	tests := []struct {
+		name       string
+		item       string
+		dest       string
+		key        string
+		answer     string
+		wantStatus string
+		want       map[string]string
+	}{
+		{
+			name:       "cancel",
+			item:       "readme.txt",
+			dest:       "2024",
+			key:        "p",
+			answer:     "n",
+			wantStatus: "Paste cancelled",
+			want:       map[string]string{"2024/readme.txt": "old readme", "readme.txt": "readme.txt"},
+		},
+		{
+			name:       "overwrite",
+			item:       "readme.txt",
+			dest:       "2024",
+			key:        "p",
+			answer:     "o",
+			wantStatus: "Queued 1 copy transfers",
+			want:       map[string]string{"2024/readme.txt": "readme.txt", "readme.txt": "readme.txt"},
+		},
+		{
+			name:       "skip existing objects of a folder",
+			item:       "2024",
+			dest:       "archive",
+			key:        "p",
+			answer:     "s",
+			wantStatus: "skipped 1 existing objects",
+			want: map[string]string{
+				"archive/2024/c.jpg":     "2024/c.jpg",
+				"archive/2024/jan/a.jpg": "archived a",
+				"archive/2024/jan/b.jpg": "2024/jan/b.jpg",
+			},
+		},
+		{
+			name:       "move with overwrite",
+			item:       "readme.txt",
+			dest:       "2024",
+			key:        "P",
+			answer:     "o",
+			wantStatus: "Queued 1 move transfers",
+			want:       map[string]string{"2024/readme.txt": "readme.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := copyStore()
+			m := paste(t, b, tt.item, tt.dest, tt.key)
+			if m.confirmCopy == nil {
+				t.Fatalf("paste onto existing objects was not confirmed, status %q", m.statusMsg)
+			}
+			if !strings.Contains(m.View(), "already exist") {
+				t.Error("the conflict dialog is not shown")
+			}
+
+			m, _ = press(m, tt.answer)
+			if m.confirmCopy != nil {
+				t.Error("the conflict dialog stayed open")
+			}
+			if !strings.Contains(m.statusMsg, tt.wantStatus) {
+				t.Errorf("status = %q, want it to contain %q", m.statusMsg, tt.wantStatus)
+			}
+			waitTransfers(t, m)
+			for name, want := range tt.want {
+				if got := content(t, b, name); got != want {
+					t.Errorf("photos/%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPasteWithoutConflicts(t *testing.T) {
+	b := copyStore()
+	m := paste(t, b, "notes.txt", "2024", "p")
+	if m.confirmCopy != nil {
+		t.Fatal("asked about conflicts when nothing is replaced")
+	}
+	waitTransfers(t, m)
+	if got := content(t, b, "2024/notes.txt"); got != "notes" {
+		t.Errorf("2024/notes.txt = %q, want %q", got, "notes")
+	}
+}
+
+func TestRenameConflict(t *testing.T) {
+	b := copyStore()
+	m := newTestModel(t, b, "photos")
+	m, _ = press(selectItem(t, m, "readme.txt"), "R")
+	m.renameInput.SetValue("notes.txt")
+	m = pressAndRun(m, "enter")
+	if m.confirmCopy == nil {
+		t.Fatalf("rename onto an existing object was not confirmed, status %q", m.statusMsg)
+	}
+	if !strings.Contains(m.View(), "mem://photos/notes.txt already exists") {
+		t.Error("the conflict dialog does not name the existing object")
+	}
+
+	m, _ = press(m, "esc")
+	if got, want := m.statusMsg, "Rename cancelled"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+	if got := content(t, b, "notes.txt"); got != "notes" {
+		t.Errorf("notes.txt = %q after cancelling the rename", got)
+	}
+	if got := content(t, b, "readme.txt"); got != "readme.txt" {
+		t.Errorf("readme.txt = %q after cancelling the rename", got)
+	}
+}
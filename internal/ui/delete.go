@@ -0,0 +1,132 @@
+package ui
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+)
+
+// deleteWorkers is the number of objects deleted concurrently
+const deleteWorkers = 8
+
+var (
+	modalStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#CF4A4A")).
+			Padding(1, 2)
+
+	modalTitleStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#CF4A4A"))
+)
+
+// deletePlan describes the objects a delete would remove, shown for
+// confirmation before anything is deleted
+type deletePlan struct {
+	label     string
+	objects   []backend.Item
+	totalSize int64
+}
+
+// planDelete lists the objects that deleting items would remove. Prefix
+// items expand to every object below them.
+func (m Model) planDelete(items []backend.Item) tea.Cmd {
+	ctx := m.planning.ctx
+	label := fmt.Sprintf("%d marked items in %s", len(items), m.currentPath)
+	if len(items) == 1 {
+		label = items[0].FullPath
+		if items[0].IsDir {
+			label = backend.NormalizePrefix(label)
+		}
+	}
+
+	return func() tea.Msg {
+		plan := deletePlan{label: label}
+		for _, item := range items {
+			if !item.IsDir {
+				plan.objects = append(plan.objects, item)
+				continue
+			}
+
+			bucketName, objectName := backend.ParsePath(item.FullPath)
+			objects, err := m.backend.ListRecursive(ctx, bucketName, backend.NormalizePrefix(objectName))
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return errMsg{err}
+			}
+			plan.objects = append(plan.objects, objects...)
+		}
+
+		for _, obj := range plan.objects {
+			plan.totalSize += obj.Size
+		}
+		return deletePlanMsg{plan}
+	}
+}
+
+// deleteObjects deletes the planned objects concurrently. A confirmed
+// delete runs until it is done or stopped with its own key, never when
+// other operations are cancelled.
+func (m Model) deleteObjects(plan deletePlan) tea.Cmd {
+	ctx := m.deleteJob.ctx
+	destPath := m.currentPath
+	var done atomic.Int64
+	total := len(plan.objects)
+
+	work := func() tea.Msg {
+		failed, err := forEach(ctx, deleteWorkers, plan.objects, func(obj backend.Item) error {
+			defer done.Add(1)
+			bucketName, _ := backend.ParsePath(obj.FullPath)
+			return m.backend.Delete(ctx, bucketName, obj.Path)
+		})
+
+		return deleteDoneMsg{
+			path:    destPath,
+			deleted: int(done.Load()) - failed,
+			total:   total,
+			failed:  failed,
+			err:     err,
+		}
+	}
+
+	progress := func() tea.Msg {
+		return deleteProgressMsg{done: int(done.Load()), total: total}
+	}
+
+	return startJob(work, progress)
+}
+
+// renderDeleteConfirm renders the delete confirmation modal
+func (m Model) renderDeleteConfirm() string {
+	plan := m.confirmDelete
+	body := fmt.Sprintf("Delete %d objects (%s) under\n%s?\n\nThis cannot be undone.\n\n[y] delete   [n] cancel",
+		len(plan.objects), formatSize(plan.totalSize), plan.label)
+	if len(plan.objects) == 1 && plan.objects[0].FullPath == plan.label {
+		body = fmt.Sprintf("Delete %s (%s)?\n\nThis cannot be undone.\n\n[y] delete   [n] cancel",
+			plan.label, formatSize(plan.totalSize))
+	}
+
+	return modalStyle.Render(modalTitleStyle.Render("Delete") + "\n\n" + body)
+}
+
+type deletePlanMsg struct {
+	plan deletePlan
+}
+
+type deleteProgressMsg struct {
+	done  int
+	total int
+}
+
+type deleteDoneMsg struct {
+	path    string
+	deleted int
+	total   int
+	failed  int
+	err     error
+}
@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+)
+
+// heldDeletes is a store whose deletes wait until they are released, apart
+// from the first few
+type heldDeletes struct {
+	*memory.Backend
+	free    atomic.Int32
+	passed  chan struct{}
+	release chan struct{}
+}
+
+func (b *heldDeletes) Delete(ctx context.Context, bucketName, objectName string) error {
+	if b.free.Add(-1) >= 0 {
+		defer func() { b.passed <- struct{}{} }()
+		return b.Backend.Delete(ctx, bucketName, objectName)
+	}
+	select {
+	case <-b.release:
+		return b.Backend.Delete(ctx, bucketName, objectName)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newHeldDeletes returns a store with n objects under photos/old/ whose
+// first free deletes go through at once
+func newHeldDeletes(n, free int) *heldDeletes {
+	b := &heldDeletes{Backend: testStore(), passed: make(chan struct{}, n), release: make(chan struct{})}
+	b.free.Store(int32(free))
+	for i := 0; i < n; i++ {
+		b.PutObject("photos", fmt.Sprintf("old/%02d.jpg", i), memory.Object{Data: []byte("x")})
+	}
+	return b
+}
+
+// confirmDelete plans the delete of the folder old and confirms it,
+// returning the command that runs the delete
+func confirmDelete(t *testing.T, m Model) (Model, tea.Cmd) {
+	t.Helper()
+	m = pressAndRun(selectItem(t, m, "old"), "x")
+	if m.confirmDelete == nil {
+		t.Fatalf("delete was not confirmed, status %q", m.statusMsg)
+	}
+	m, cmd := press(m, "y")
+	if !m.deleting {
+		t.Fatal("confirming did not start the delete")
+	}
+	return m, cmd
+}
+
+func TestEscKeepsDeleting(t *testing.T) {
+	b := newHeldDeletes(20, 0)
+	m, cmd := confirmDelete(t, newTestModel(t, b, "photos"))
+
+	m, _ = press(m, "esc")
+	close(b.release)
+	m = run(m, cmd)
+
+	if m.deleting {
+		t.Error("still deleting after the delete finished")
+	}
+	if got, want := m.statusMsg, "Deleted 20 objects"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+	for _, name := range itemNames(m) {
+		if name == "old" {
+			t.Errorf("old is still listed after deleting it")
+		}
+	}
+}
+
+func TestStopDelete(t *testing.T) {
+	b := newHeldDeletes(20, 2)
+	m, cmd := confirmDelete(t, newTestModel(t, b, "photos"))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-b.passed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the first deletes did not go through")
+		}
+	}
+	m, _ = press(m, "X")
+	if got, want := m.statusMsg, "Stopping delete..."; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+
+	m = run(m, cmd)
+	if m.deleting {
+		t.Error("still deleting after the delete was stopped")
+	}
+	if got, want := m.statusMsg, "Delete stopped, deleted 2 of 20 objects"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+	if got := len(objectsUnder(t, b, "old/")); got != 18 {
+		t.Errorf("%d objects left, want 18", got)
+	}
+}
+
+func TestStopDeleteWithoutDelete(t *testing.T) {
+	m := newTestModel(t, testStore(), "photos")
+	m, _ = press(m, "X")
+	if got, want := m.statusMsg, "No delete is running"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+}
+
+// objectsUnder returns the objects below prefix in the photos bucket
+func objectsUnder(t *testing.T, b backend.Backend, prefix string) []backend.Item {
+	t.Helper()
+	items, err := b.ListRecursive(context.Background(), "photos", prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
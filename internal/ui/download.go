@@ -0,0 +1,491 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/transfer"
+)
+
+var (
+	downloadModalStyle = modalStyle.
+				BorderForeground(lipgloss.Color("#4A86CF"))
+
+	downloadModalTitleStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#4A86CF"))
+)
+
+// downloadTarget is an object queued for download to a path relative to
+// the download directory
+type downloadTarget struct {
+	item      backend.Item
+	localPath string
+}
+
+// downloadPlan describes a download, shown for confirmation when it would
+// replace existing files. Compressed objects of decompressing downloads are
+// saved under their names without the compression extension. The targets
+// are files in archive if it is set.
+type downloadPlan struct {
+	dir        string
+	targets    []downloadTarget
+	conflicts  int
+	decompress bool
+	archive    *archive
+}
+
+// planDownload lists the objects that downloading items into dir would
+// fetch and counts the local files they would replace. Files that already
+// hold an object's content are not counted.
+func (m Model) planDownload(items []backend.Item, dir string, decompress bool) tea.Cmd {
+	ctx := m.planning.ctx
+	a := m.archive
+	return func() tea.Msg {
+		var targets []downloadTarget
+		var err error
+		if a != nil {
+			targets = a.downloads(items)
+		} else {
+			targets, err = expandDownloads(ctx, m.backend, items)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg{err}
+		}
+
+		plan := downloadPlan{dir: dir, targets: targets, decompress: decompress, archive: a}
+		for i, t := range targets {
+			if decompress {
+				t.localPath = decompressedName(t.localPath)
+				targets[i] = t
+			}
+			localPath := filepath.Join(dir, t.localPath)
+			if _, err := os.Stat(localPath); err != nil {
+				continue
+			}
+			// Files saveObject would leave alone are not conflicts
+			if a == nil && !decompress && m.upToDate(ctx, t.item, localPath) {
+				continue
+			}
+			plan.conflicts++
+		}
+		return downloadPlanMsg{plan}
+	}
+}
+
+// expandDownloads maps items onto the local files they download to.
+// Folders and buckets expand to every object below them, in a local
+// directory named after them that mirrors the object hierarchy.
+func expandDownloads(ctx context.Context, b backend.Backend, items []backend.Item) ([]downloadTarget, error) {
+	var targets []downloadTarget
+	for _, item := range items {
+		if !item.IsDir {
+			targets = append(targets, downloadTarget{item, item.Name})
+			continue
+		}
+
+		bucketName, objectName := backend.ParsePath(item.FullPath)
+		prefix := backend.NormalizePrefix(objectName)
+		objects, err := b.ListRecursive(ctx, bucketName, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range objects {
+			rel := strings.TrimPrefix(obj.Path, prefix)
+
+			// Skip folder placeholder objects
+			if rel == "" || strings.HasSuffix(rel, "/") {
+				continue
+			}
+			targets = append(targets, downloadTarget{obj, filepath.Join(item.Name, filepath.FromSlash(rel))})
+		}
+	}
+	return targets, nil
+}
+
+// unchanged reports whether localPath already holds the content of an
+// object with the given attributes. Objects without checksums are never
+// considered unchanged.
+func unchanged(localPath string, attrs backend.Attrs) bool {
+	info, err := os.Stat(localPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != attrs.Size {
+		return false
+	}
+
+	verifier := backend.NewVerifier(attrs)
+	if !verifier.Checked() {
+		return false
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(verifier, f); err != nil {
+		return false
+	}
+	return verifier.Verify() == nil
+}
+
+// upToDate reports whether localPath already holds the content of the
+// object item. Only files of the object's size are checked, which takes
+// the object's checksums and a read of the file.
+func (m Model) upToDate(ctx context.Context, item backend.Item, localPath string) bool {
+	info, err := os.Stat(localPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != item.Size {
+		return false
+	}
+
+	bucketName, _ := backend.ParsePath(item.FullPath)
+	reader, attrs, err := m.backend.NewRangeReader(ctx, bucketName, item.Path, 0, 0)
+	if err != nil {
+		return false
+	}
+	reader.Close()
+	return unchanged(localPath, attrs)
+}
+
+// freeName returns fileName with the first " (n)" suffix that does not
+// exist yet, e.g. "report (1).csv"
+func freeName(fileName string) string {
+	ext := filepath.Ext(fileName)
+	base := strings.TrimSuffix(fileName, ext)
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(name); errors.Is(err, fs.ErrNotExist) {
+			return name
+		}
+	}
+}
+
+// saveObject streams an object into localPath below dir, reporting the
+// bytes read to p. The content goes to a hidden partial file next to the
+// destination that is only renamed into place once it is complete and
+// matches the object's checksums, so a failed or cancelled download never
+// leaves a truncated file behind. An interrupted download keeps its partial
+// file together with the object's generation and the offset reached, and
+// the next attempt resumes from there with a ranged read unless the object
+// has changed in the meantime. Files that already hold the object's
+// content are left alone, while other existing files are handled
+// according to policy.
+func (m Model) saveObject(ctx context.Context, item backend.Item, dir, localPath string, policy ConflictPolicy, p *transfer.Progress) error {
+	if !filepath.IsLocal(localPath) {
+		return fmt.Errorf("refusing to write outside the download directory")
+	}
+	dest := filepath.Join(dir, localPath)
+	partPath, statePath := partialPaths(dest)
+	if _, busy := activeParts.LoadOrStore(partPath, true); busy {
+		return fmt.Errorf("%s is already being downloaded", dest)
+	}
+	defer activeParts.Delete(partPath)
+
+	bucketName, _ := backend.ParsePath(item.FullPath)
+	state, resume := loadPartial(partPath, statePath, bucketName, item.Path)
+	offset := int64(0)
+	if resume {
+		offset = state.Offset
+	}
+
+	reader, attrs, err := m.backend.NewRangeReader(ctx, bucketName, item.Path, offset, -1)
+	if err != nil {
+		return err
+	}
+
+	// Refuse to resume from a different version of the object
+	changed := resume && (attrs.Generation != state.Generation || attrs.Size != state.Size)
+	if changed {
+		reader.Close()
+		removePartial(partPath, statePath)
+		resume, offset = false, 0
+		if reader, attrs, err = m.backend.NewReader(ctx, bucketName, item.Path); err != nil {
+			return err
+		}
+	}
+	defer reader.Close()
+
+	fileName := dest
+	if unchanged(fileName, attrs) {
+		removePartial(partPath, statePath)
+		p.Add(attrs.Size)
+		p.SetNote("already up to date")
+		return nil
+	}
+	if _, err := os.Lstat(fileName); err == nil {
+		switch policy {
+		case ConflictSkip:
+			removePartial(partPath, statePath)
+			p.Add(attrs.Size)
+			p.SetNote("skipped, file exists")
+			return nil
+		case ConflictRename:
+			fileName = freeName(fileName)
+		}
+	}
+
+	if !resume {
+		os.Remove(statePath)
+	}
+	verifier := backend.NewVerifier(attrs)
+	f, err := openPartial(partPath, offset, verifier)
+	if err != nil {
+		return err
+	}
+	p.Add(offset)
+
+	w := &checkpointWriter{f: f}
+	if attrs.Generation != "" {
+		w.statePath = statePath
+		w.state = partialState{
+			Bucket:     bucketName,
+			Object:     item.Path,
+			Generation: attrs.Generation,
+			Size:       attrs.Size,
+			Offset:     offset,
+		}
+	}
+
+	_, err = io.Copy(io.MultiWriter(w, verifier, p), reader)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		// Keep what was downloaded so far for the next attempt
+		keep := w.statePath != "" && w.checkpoint() == nil
+		f.Close()
+		if !keep {
+			removePartial(partPath, statePath)
+		}
+		return err
+	}
+
+	if err := verifier.Verify(); err != nil {
+		f.Close()
+		removePartial(partPath, statePath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, fileName); err != nil {
+		return err
+	}
+	os.Remove(statePath)
+
+	var notes []string
+	switch {
+	case changed:
+		notes = append(notes, "object changed since the interrupted download, downloaded again")
+	case resume:
+		notes = append(notes, "resumed at "+formatSize(offset))
+	}
+	if fileName != dest {
+		notes = append(notes, "saved as "+filepath.Base(fileName))
+	}
+	if verifier.Checked() {
+		notes = append(notes, "checksum verified")
+	}
+	p.SetNote(strings.Join(notes, ", "))
+	return nil
+}
+
+// openPartial opens the partial file of a download. When resuming at
+// offset, the bytes already downloaded are kept and fed to verifier, so
+// that the checksums cover the whole object; otherwise the file starts
+// out empty.
+func openPartial(partPath string, offset int64, verifier io.Writer) (*os.File, error) {
+	if offset == 0 {
+		if err := os.MkdirAll(filepath.Dir(partPath), 0o755); err != nil {
+			return nil, err
+		}
+		return os.OpenFile(partPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	}
+
+	f, err := os.OpenFile(partPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := io.CopyN(verifier, f, offset); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error reading partial download: %v", err)
+	}
+	return f, nil
+}
+
+// saveDecompressed streams an object into localPath below dir like
+// saveObject, decompressing it on the way if its content is compressed.
+// The object's checksums are verified against the compressed bytes as they
+// are read.
+func (m Model) saveDecompressed(ctx context.Context, item backend.Item, dir, localPath string, policy ConflictPolicy, p *transfer.Progress) error {
+	bucketName, _ := backend.ParsePath(item.FullPath)
+	return saveStream(ctx, item.Name, func(ctx context.Context) (io.ReadCloser, backend.Attrs, error) {
+		return m.backend.NewReader(ctx, bucketName, item.Path)
+	}, dir, localPath, policy, true, p)
+}
+
+// saveMember extracts a file of archive a into localPath below dir,
+// decompressing it if decompress is set. Only the member's data is read
+// where the archive format allows it.
+func (m Model) saveMember(ctx context.Context, a *archive, item backend.Item, dir, localPath string, policy ConflictPolicy, decompress bool, p *transfer.Progress) error {
+	return saveStream(ctx, item.Name, func(ctx context.Context) (io.ReadCloser, backend.Attrs, error) {
+		return a.open(ctx, m.backend, item.Path)
+	}, dir, localPath, policy, decompress, p)
+}
+
+// saveStream streams content called name that cannot be resumed into
+// localPath below dir through a partial file like saveObject, always
+// starting over. With decompress set, compressed content is decompressed
+// on the way and content that is not compressed is saved as it is. Any
+// checksums in the attributes open returns are verified against the bytes
+// read.
+func saveStream(ctx context.Context, name string, open func(context.Context) (io.ReadCloser, backend.Attrs, error), dir, localPath string, policy ConflictPolicy, decompress bool, p *transfer.Progress) error {
+	if !filepath.IsLocal(localPath) {
+		return fmt.Errorf("refusing to write outside the download directory")
+	}
+	dest := filepath.Join(dir, localPath)
+	partPath, statePath := partialPaths(dest)
+	if _, busy := activeParts.LoadOrStore(partPath, true); busy {
+		return fmt.Errorf("%s is already being downloaded", dest)
+	}
+	defer activeParts.Delete(partPath)
+
+	reader, attrs, err := open(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	fileName := dest
+	if _, err := os.Lstat(fileName); err == nil {
+		switch policy {
+		case ConflictSkip:
+			p.Add(attrs.Size)
+			p.SetNote("skipped, file exists")
+			return nil
+		case ConflictRename:
+			fileName = freeName(fileName)
+		}
+	}
+
+	verifier := backend.NewVerifier(attrs)
+	raw := io.TeeReader(reader, io.MultiWriter(verifier, p))
+	var src io.Reader = raw
+	var c *codec
+	if decompress {
+		decompressed, sniffed, err := decompressReader(raw, name, attrs.ContentEncoding)
+		if err != nil {
+			return err
+		}
+		defer decompressed.Close()
+		src, c = decompressed, sniffed
+	}
+
+	os.Remove(statePath)
+	f, err := openPartial(partPath, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	// Decompressors may stop before the end of the content, so whatever
+	// follows is still read to verify the checksums
+	n, err := io.Copy(f, src)
+	if err == nil {
+		_, err = io.Copy(io.Discard, raw)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = verifier.Verify()
+	}
+	if err != nil {
+		f.Close()
+		removePartial(partPath, statePath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(partPath, fileName); err != nil {
+		return err
+	}
+
+	var notes []string
+	switch {
+	case c != nil:
+		notes = append(notes, fmt.Sprintf("decompressed %s, %s to %s", c.name, formatSize(attrs.Size), formatSize(n)))
+	case decompress:
+		notes = append(notes, "not compressed, saved as is")
+	}
+	if fileName != dest {
+		notes = append(notes, "saved as "+filepath.Base(fileName))
+	}
+	if verifier.Checked() {
+		notes = append(notes, "checksum verified")
+	}
+	p.SetNote(strings.Join(notes, ", "))
+	return nil
+}
+
+// startDownload queues a transfer for every object of a planned download,
+// handling existing files according to policy
+func (m Model) startDownload(plan downloadPlan, policy ConflictPolicy) (Model, tea.Cmd) {
+	save := m.saveObject
+	switch {
+	case plan.archive != nil:
+		saveMember := m.saveMember
+		save = func(ctx context.Context, item backend.Item, dir, localPath string, policy ConflictPolicy, p *transfer.Progress) error {
+			return saveMember(ctx, plan.archive, item, dir, localPath, policy, plan.decompress, p)
+		}
+	case plan.decompress:
+		save = m.saveDecompressed
+	}
+	for _, t := range plan.targets {
+		m.queue.Add(transfer.Download, filepath.Join(plan.dir, t.localPath), plan.dir, t.item.Size,
+			func(ctx context.Context, p *transfer.Progress) error {
+				return save(ctx, t.item, plan.dir, t.localPath, policy, p)
+			})
+	}
+
+	m.statusMsg = fmt.Sprintf("Queued %d downloads to %s", len(plan.targets), plan.dir)
+	if plan.decompress {
+		m.statusMsg = fmt.Sprintf("Queued %d decompressing downloads to %s", len(plan.targets), plan.dir)
+	}
+	return m.watchTransfers()
+}
+
+// renderDownloadConfirm renders the dialog asking what to do with the
+// files a download would replace
+func (m Model) renderDownloadConfirm() string {
+	plan := m.confirmDownload
+	body := fmt.Sprintf("%d of %d files already exist with other content in\n%s\n\n[o] overwrite   [s] skip   [r] rename   [n] cancel",
+		plan.conflicts, len(plan.targets), plan.dir)
+	if len(plan.targets) == 1 {
+		body = fmt.Sprintf("%s already exists with other content\n\n[o] overwrite   [s] skip   [r] rename   [n] cancel",
+			filepath.Join(plan.dir, plan.targets[0].localPath))
+	}
+
+	return downloadModalStyle.Render(downloadModalTitleStyle.Render("Download") + "\n\n" + body)
+}
+
+type downloadPlanMsg struct {
+	plan downloadPlan
+}
@@ -0,0 +1,429 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+	"github.com/fernandoabolafio/lazybucket/internal/transfer"
+)
+
+// errCut is the error of a read that was cut off
+var errCut = errors.New("connection reset")
+
+// cutReads is a store that records the offsets of ranged reads and cuts
+// them off after a number of bytes, calling onCut first
+type cutReads struct {
+	*memory.Backend
+
+	mu      sync.Mutex
+	offsets []int64
+	after   int64
+	onCut   func()
+}
+
+func (b *cutReads) NewRangeReader(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, backend.Attrs, error) {
+	reader, attrs, err := b.Backend.NewRangeReader(ctx, bucketName, objectName, offset, length)
+	if err != nil {
+		return nil, attrs, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.offsets = append(b.offsets, offset)
+	if b.after > 0 {
+		reader = &cutReader{ReadCloser: reader, left: b.after, onCut: b.onCut}
+	}
+	return reader, attrs, nil
+}
+
+// cut makes the following reads fail after n bytes, or not at all if n is 0
+func (b *cutReads) cut(n int64, onCut func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.after, b.onCut, b.offsets = n, onCut, nil
+}
+
+// cutReader fails with errCut once left bytes are read
+type cutReader struct {
+	io.ReadCloser
+	left  int64
+	onCut func()
+}
+
+func (r *cutReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		if r.onCut != nil {
+			r.onCut()
+		}
+		return 0, errCut
+	}
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= int64(n)
+	return n, err
+}
+
+// randomData returns size bytes that differ with seed
+func randomData(size int, seed int64) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// readState reads the saved state of a partial download
+func readState(t *testing.T, statePath string) partialState {
+	t.Helper()
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state partialState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+// interruptedDownload stores data as photos/big.bin and downloads it into
+// dir, cutting the download off after cut bytes. It returns the model,
+// the item and the state saved for the partial download.
+func interruptedDownload(t *testing.T, b *cutReads, dir string, data []byte, cut int64) (Model, backend.Item, partialState) {
+	t.Helper()
+	b.PutObject("photos", "big.bin", memory.Object{Data: data})
+	m := newTestModel(t, b, "photos")
+	item := backend.Item{Name: "big.bin", Path: "big.bin", FullPath: "photos/big.bin", Size: int64(len(data))}
+
+	b.cut(cut, nil)
+	err := m.saveObject(context.Background(), item, dir, "big.bin", ConflictOverwrite, &transfer.Progress{})
+	if !errors.Is(err, errCut) {
+		t.Fatalf("interrupted download returned %v, want %v", err, errCut)
+	}
+	b.cut(0, nil)
+
+	partPath, statePath := partialPaths(filepath.Join(dir, "big.bin"))
+	state := readState(t, statePath)
+	info, err := os.Stat(partPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Offset != cut || info.Size() != cut {
+		t.Fatalf("partial download holds %d bytes with offset %d, want %d", info.Size(), state.Offset, cut)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "big.bin")); err == nil {
+		t.Fatal("the interrupted download left big.bin behind")
+	}
+	return m, item, state
+}
+
+// checkDownloaded checks that dir holds want as big.bin and no partial files
+func checkDownloaded(t *testing.T, dir string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(filepath.Join(dir, "big.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("big.bin does not hold the object's content")
+	}
+	partPath, statePath := partialPaths(filepath.Join(dir, "big.bin"))
+	for _, name := range []string{partPath, statePath} {
+		if _, err := os.Stat(name); err == nil {
+			t.Errorf("%s is left behind", filepath.Base(name))
+		}
+	}
+}
+
+func TestResumeDownload(t *testing.T) {
+	b := &cutReads{Backend: testStore()}
+	dir := t.TempDir()
+	data := randomData(3<<20, 1)
+	m, item, state := interruptedDownload(t, b, dir, data, 1<<20)
+	if state.Bucket != "photos" || state.Object != "big.bin" || state.Size != int64(len(data)) || state.Generation == "" {
+		t.Errorf("saved state %+v does not describe photos/big.bin", state)
+	}
+
+	p := &transfer.Progress{}
+	if err := m.saveObject(context.Background(), item, dir, "big.bin", ConflictOverwrite, p); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.offsets) != 1 || b.offsets[0] != 1<<20 {
+		t.Errorf("resumed with reads at %v, want one at %d", b.offsets, 1<<20)
+	}
+	checkDownloaded(t, dir, data)
+}
+
+func TestDownloadCheckpoints(t *testing.T) {
+	b := &cutReads{Backend: testStore()}
+	dir := t.TempDir()
+	data := randomData(checkpointInterval+checkpointInterval/2, 1)
+	b.PutObject("photos", "big.bin", memory.Object{Data: data})
+	m := newTestModel(t, b, "photos")
+	item := backend.Item{Name: "big.bin", Path: "big.bin", FullPath: "photos/big.bin", Size: int64(len(data))}
+
+	// The state on disk when the read fails is the last checkpoint
+	_, statePath := partialPaths(filepath.Join(dir, "big.bin"))
+	var saved partialState
+	b.cut(checkpointInterval+1<<20, func() { saved = readState(t, statePath) })
+	err := m.saveObject(context.Background(), item, dir, "big.bin", ConflictOverwrite, &transfer.Progress{})
+	if !errors.Is(err, errCut) {
+		t.Fatalf("interrupted download returned %v, want %v", err, errCut)
+	}
+	if saved.Offset != checkpointInterval {
+		t.Errorf("checkpointed offset %d before the failure, want %d", saved.Offset, checkpointInterval)
+	}
+	if state := readState(t, statePath); state.Offset != checkpointInterval+1<<20 {
+		t.Errorf("saved offset %d after the failure, want %d", state.Offset, checkpointInterval+1<<20)
+	}
+}
+
+func TestResumeChangedObject(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"new generation", randomData(3<<20, 2)},
+		{"new size", randomData(4<<20, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &cutReads{Backend: testStore()}
+			dir := t.TempDir()
+			m, item, _ := interruptedDownload(t, b, dir, randomData(3<<20, 1), 1<<20)
+
+			b.PutObject("photos", "big.bin", memory.Object{Data: tt.data})
+			item.Size = int64(len(tt.data))
+			if err := m.saveObject(context.Background(), item, dir, "big.bin", ConflictOverwrite, &transfer.Progress{}); err != nil {
+				t.Fatal(err)
+			}
+			checkDownloaded(t, dir, tt.data)
+		})
+	}
+}
+
+func TestResumeDamagedPartial(t *testing.T) {
+	b := &cutReads{Backend: testStore()}
+	dir := t.TempDir()
+	data := randomData(3<<20, 1)
+	m, item, _ := interruptedDownload(t, b, dir, data, 1<<20)
+
+	// A partial file shorter than its recorded offset starts over
+	partPath, _ := partialPaths(filepath.Join(dir, "big.bin"))
+	if err := os.Truncate(partPath, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.saveObject(context.Background(), item, dir, "big.bin", ConflictOverwrite, &transfer.Progress{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.offsets) != 1 || b.offsets[0] != 0 {
+		t.Errorf("downloaded with reads at %v, want one from the start", b.offsets)
+	}
+	checkDownloaded(t, dir, data)
+}
+
+func TestResumeThroughQueue(t *testing.T) {
+	b := &cutReads{Backend: testStore()}
+	data := randomData(3<<20, 1)
+	b.PutObject("photos", "big.bin", memory.Object{Data: data})
+	m := newTestModel(t, b, "photos")
+	dir := t.TempDir()
+	m.options.DownloadDir = dir
+
+	b.cut(1<<20, nil)
+	m = pressAndRun(selectItem(t, m, "big.bin"), "d")
+	waitTransfers(t, m)
+	list := m.queue.List()
+	if len(list) != 1 || list[0].State != transfer.Failed {
+		t.Fatalf("transfers = %+v, want one failed download", list)
+	}
+
+	b.cut(0, nil)
+	m.queue.Retry(list[0].ID)
+	waitTransfers(t, m)
+	if got := m.queue.List()[0]; got.State != transfer.Done || got.Note == "" {
+		t.Errorf("retried download is %v with note %q", got.State, got.Note)
+	}
+	checkDownloaded(t, dir, data)
+}
+
+// writeFiles creates files below dir with the given content
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFiles returns the content of every file below dir by slash path
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestPlanDownload(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"2024/c.jpg":     "2024/c.jpg",
+		"2024/jan/a.jpg": "old",
+		"readme.txt":     "readme.tx!",
+	})
+	m := newTestModel(t, testStore(), "photos")
+	items := []backend.Item{
+		{Name: "2024", Path: "2024/", FullPath: "photos/2024", IsDir: true},
+		{Name: "readme.txt", Path: "readme.txt", FullPath: "photos/readme.txt", Size: 10},
+	}
+
+	tests := []struct {
+		name       string
+		decompress bool
+		conflicts  int
+	}{
+		// c.jpg holds its object's content and is downloaded no matter what
+		{"download", false, 2},
+		// Decompressing downloads always write the file again
+		{"decompressing download", true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, ok := m.planDownload(items, dir, tt.decompress)().(downloadPlanMsg)
+			if !ok {
+				t.Fatal("planning did not return a plan")
+			}
+			var got []string
+			for _, target := range msg.plan.targets {
+				got = append(got, filepath.ToSlash(target.localPath))
+			}
+			want := []string{"2024/c.jpg", "2024/jan/a.jpg", "2024/jan/b.jpg", "readme.txt"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("targets = %v, want %v", got, want)
+			}
+			if msg.plan.conflicts != tt.conflicts {
+				t.Errorf("%d conflicts, want %d", msg.plan.conflicts, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestDownloadConflictPolicies(t *testing.T) {
+	tests := []struct {
+		key  string
+		want map[string]string
+	}{
+		{"o", map[string]string{
+			"2024/c.jpg":     "2024/c.jpg",
+			"2024/jan/a.jpg": "2024/jan/a.jpg",
+			"2024/jan/b.jpg": "2024/jan/b.jpg",
+		}},
+		{"s", map[string]string{
+			"2024/c.jpg":     "2024/c.jpg",
+			"2024/jan/a.jpg": "old",
+			"2024/jan/b.jpg": "2024/jan/b.jpg",
+		}},
+		{"r", map[string]string{
+			"2024/c.jpg":         "2024/c.jpg",
+			"2024/jan/a.jpg":     "old",
+			"2024/jan/a (1).jpg": "2024/jan/a.jpg",
+			"2024/jan/b.jpg":     "2024/jan/b.jpg",
+		}},
+		{"n", map[string]string{
+			"2024/c.jpg":     "2024/c.jpg",
+			"2024/jan/a.jpg": "old",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"2024/c.jpg": "2024/c.jpg", "2024/jan/a.jpg": "old"})
+			m := newTestModel(t, testStore(), "photos")
+			m.options.DownloadDir = dir
+
+			m = pressAndRun(selectItem(t, m, "2024"), "d")
+			if m.confirmDownload == nil {
+				t.Fatalf("no conflict prompt, status %q", m.statusMsg)
+			}
+			if got := m.confirmDownload.conflicts; got != 1 {
+				t.Errorf("%d conflicts, want a.jpg only", got)
+			}
+			m = pressAndRun(m, tt.key)
+			waitTransfers(t, m)
+			if got := readFiles(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"readme.txt": "readme.txt"})
+	m := newTestModel(t, testStore(), "photos")
+	m.options.DownloadDir = dir
+
+	m = pressAndRun(selectItem(t, m, "readme.txt"), "d")
+	if m.confirmDownload != nil {
+		t.Fatal("a file holding the object's content prompts for a conflict")
+	}
+	waitTransfers(t, m)
+	list := m.queue.List()
+	if len(list) != 1 || list[0].State != transfer.Done || list[0].Note != "already up to date" {
+		t.Errorf("transfers = %+v, want one up to date download", list)
+	}
+}
+
+func TestFreeName(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"report.csv":     "",
+		"report (1).csv": "",
+		"README":         "",
+		"logs.tar.gz":    "",
+	})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.csv", "report (2).csv"},
+		{"README", "README (1)"},
+		{"logs.tar.gz", "logs.tar (1).gz"},
+		{"new.txt", "new (1).txt"},
+	}
+	for _, tt := range tests {
+		if got := freeName(filepath.Join(dir, tt.name)); got != filepath.Join(dir, tt.want) {
+			t.Errorf("freeName(%q) = %q, want %q", tt.name, filepath.Base(got), tt.want)
+		}
+	}
+}
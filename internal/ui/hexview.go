@@ -0,0 +1,367 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+)
+
+const (
+	// hexWindow is the number of bytes of a binary object held in memory.
+	// Other parts of the object are fetched when the cursor moves there.
+	hexWindow = 64 << 10
+
+	// hexRowSize is the number of bytes shown per row
+	hexRowSize = 16
+
+	// sniffSize is how much of an object is inspected to tell binary
+	// content from text
+	sniffSize = 8 << 10
+)
+
+// isBinary reports whether data looks like the start of a binary object:
+// it holds NUL bytes, is not valid UTF-8 or is mostly control characters
+func isBinary(data []byte) bool {
+	data = data[:min(len(data), sniffSize)]
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+
+	// The sample may end in the middle of a character
+	valid := data
+	for i := 0; i < utf8.UTFMax-1 && len(valid) > 0 && !utf8.Valid(valid); i++ {
+		valid = valid[:len(valid)-1]
+	}
+	if !utf8.Valid(valid) {
+		return true
+	}
+
+	control := 0
+	for _, b := range data {
+		if b < 0x20 && !strings.ContainsRune("\t\n\r\f\b\x1b", rune(b)) || b == 0x7f {
+			control++
+		}
+	}
+	return control > len(data)/10
+}
+
+// hexView shows a binary object as a hex dump with its printable bytes
+// alongside. Only a window of the object is kept; the rest is fetched with
+// ranged reads as the cursor moves there.
+type hexView struct {
+	bucketName string
+	objectName string
+	size       int64
+	generation string
+
+	// data holds the bytes from start on. pending is the start of the
+	// window being fetched, or -1.
+	data    []byte
+	start   int64
+	pending int64
+	err     error
+
+	cursor int64
+	top    int64
+}
+
+// newHexView creates a hex view of an object whose first bytes are head
+func newHexView(bucketName, objectName string, attrs backend.Attrs, head []byte) *hexView {
+	return &hexView{
+		bucketName: bucketName,
+		objectName: objectName,
+		size:       attrs.Size,
+		generation: attrs.Generation,
+		data:       head,
+		pending:    -1,
+	}
+}
+
+// offsetWidth returns the number of hex digits offsets are shown with
+func (h *hexView) offsetWidth() int {
+	return max(8, len(strconv.FormatInt(h.size, 16)))
+}
+
+// lastRow returns the offset of the last row
+func (h *hexView) lastRow() int64 {
+	return max(0, h.size-1) / hexRowSize * hexRowSize
+}
+
+// moveTo moves the cursor to offset, scrolling so that it stays visible
+func (h *hexView) moveTo(offset int64, height int) {
+	h.cursor = max(0, min(offset, h.size-1))
+	row := h.cursor / hexRowSize * hexRowSize
+	page := int64(height) * hexRowSize
+	switch {
+	case row < h.top:
+		h.top = row
+	case row >= h.top+page:
+		h.top = row - page + hexRowSize
+	}
+	h.top = max(0, min(h.top, h.lastRow()-page+hexRowSize))
+}
+
+// scrollTo moves the cursor to offset and puts its row at the top
+func (h *hexView) scrollTo(offset int64, height int) {
+	h.top = max(0, min(offset, h.size-1)) / hexRowSize * hexRowSize
+	h.moveTo(offset, height)
+}
+
+// missing returns the start of the window to fetch so that every visible
+// row is loaded, or false if nothing needs fetching
+func (h *hexView) missing(height int) (int64, bool) {
+	end := min(h.size, h.top+int64(height)*hexRowSize)
+	if h.top >= h.start && end <= h.start+int64(len(h.data)) {
+		return 0, false
+	}
+
+	// Centre the window on the visible rows so that scrolling either way
+	// stays within it for a while
+	start := max(0, h.top-(hexWindow-int64(height)*hexRowSize)/2)
+	start = min(start, h.top) / hexRowSize * hexRowSize
+	if start == h.pending {
+		return 0, false
+	}
+	return start, true
+}
+
+// byteAt returns the byte at offset, or false if it is not loaded
+func (h *hexView) byteAt(offset int64) (byte, bool) {
+	i := offset - h.start
+	if i < 0 || i >= int64(len(h.data)) {
+		return 0, false
+	}
+	return h.data[i], true
+}
+
+// view renders the rows that fit into height
+func (h *hexView) view(width, height int) string {
+	if h.size == 0 {
+		return jsonDimStyle.Render("The object is empty")
+	}
+
+	lines := make([]string, 0, height)
+	for row := h.top; row < h.size && len(lines) < height; row += hexRowSize {
+		lines = append(lines, h.renderRow(row))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderRow renders the offset, hex bytes and printable bytes of a row
+func (h *hexView) renderRow(row int64) string {
+	var s strings.Builder
+	s.WriteString(lineNumberStyle.Render(fmt.Sprintf("%0*x │ ", h.offsetWidth(), row)))
+	if _, ok := h.byteAt(row); !ok {
+		s.WriteString(jsonDimStyle.Render("loading…"))
+		return s.String()
+	}
+
+	var text strings.Builder
+	for i := int64(0); i < hexRowSize; i++ {
+		if i == hexRowSize/2 {
+			s.WriteString(" ")
+		}
+		b, ok := h.byteAt(row + i)
+		if !ok || row+i >= h.size {
+			s.WriteString("   ")
+			continue
+		}
+
+		hex := fmt.Sprintf("%02x", b)
+		char := "."
+		if b >= 0x20 && b < 0x7f {
+			char = string(rune(b))
+		}
+		if row+i == h.cursor {
+			hex = tableSelectedColumnStyle.Render(hex)
+			char = tableSelectedColumnStyle.Render(char)
+		} else if b == 0 {
+			hex = jsonDimStyle.Render(hex)
+		}
+		s.WriteString(hex + " ")
+		text.WriteString(char)
+	}
+	s.WriteString(lineNumberStyle.Render("│ "))
+	s.WriteString(text.String())
+	return s.String()
+}
+
+// hexStatus describes the hex view for the status bar
+func (m Model) hexStatus() string {
+	h := m.file.hex
+	status := fmt.Sprintf("Viewing %s (binary, %s", m.file.name, formatSize(h.size))
+	if h.size > 0 {
+		status += fmt.Sprintf(", offset 0x%x (%d)", h.cursor, h.cursor)
+		if b, ok := h.byteAt(h.cursor); ok {
+			status += fmt.Sprintf(", byte 0x%02x", b)
+		}
+	}
+	switch {
+	case h.err != nil:
+		status += fmt.Sprintf(", %v", h.err)
+	case h.pending >= 0:
+		status += ", loading"
+	}
+	return status + ")"
+}
+
+// updateHexView handles keys while a binary object is shown
+func (m Model) updateHexView(msg tea.KeyMsg) (Model, tea.Cmd) {
+	h := m.file.hex
+	_, height := m.viewerSize()
+	page := int64(height) * hexRowSize
+
+	switch {
+	case key.Matches(msg, m.keyMap.Quit):
+		return m.quit()
+	case key.Matches(msg, m.keyMap.Back):
+		m.viewingFile = false
+		return m, nil
+	case key.Matches(msg, m.keyMap.Query):
+		m.querying = true
+		m.queryInput = textinput.New()
+		m.queryInput.Prompt = "Offset: "
+		m.queryInput.Placeholder = "1024 or 0x400"
+		return m, m.queryInput.Focus()
+	case key.Matches(msg, m.keyMap.Search):
+		m.statusMsg = "No search in binary objects, press : to go to an offset"
+		return m, nil
+	default:
+		switch msg.String() {
+		case "up", "k":
+			h.moveTo(h.cursor-hexRowSize, height)
+		case "down", "j":
+			h.moveTo(h.cursor+hexRowSize, height)
+		case "left", "h":
+			h.moveTo(h.cursor-1, height)
+		case "right", "l":
+			h.moveTo(h.cursor+1, height)
+		case "pgup", "ctrl+u":
+			h.moveTo(h.cursor-page, height)
+		case "pgdown", "ctrl+d":
+			h.moveTo(h.cursor+page, height)
+		case "home", "g":
+			h.moveTo(0, height)
+		case "end", "G":
+			h.moveTo(h.size-1, height)
+		default:
+			return m, nil
+		}
+	}
+
+	cmd := m.fetchHex()
+	m.statusMsg = m.viewerStatus()
+	return m, cmd
+}
+
+// updateGoToOffset handles the go to offset prompt of the hex view
+func (m Model) updateGoToOffset(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "esc":
+		m.querying = false
+		m.statusMsg = m.viewerStatus()
+		return m, nil
+	case "enter":
+		m.querying = false
+		h := m.file.hex
+		offset, err := parseOffset(m.queryInput.Value())
+		if err != nil {
+			m.statusMsg = fmt.Sprintf("Invalid offset: %v", err)
+			return m, nil
+		}
+		if offset >= h.size {
+			m.statusMsg = fmt.Sprintf("Offset %d is beyond the end of the object (%d bytes)", offset, h.size)
+			return m, nil
+		}
+		_, height := m.viewerSize()
+		h.scrollTo(offset, height)
+		cmd := m.fetchHex()
+		m.statusMsg = m.viewerStatus()
+		return m, cmd
+	}
+
+	var cmd tea.Cmd
+	m.queryInput, cmd = m.queryInput.Update(msg)
+	return m, cmd
+}
+
+// parseOffset parses a decimal or 0x-prefixed hexadecimal byte offset
+func parseOffset(s string) (int64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "_", "")
+	base := 10
+	if rest, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		s, base = rest, 16
+	}
+	offset, err := strconv.ParseInt(s, base, 64)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("%q is not a byte offset", s)
+	}
+	return offset, nil
+}
+
+// fetchHex fetches the window of the object around the visible rows if
+// they are not loaded yet. Only that range of the object is read.
+func (m Model) fetchHex() tea.Cmd {
+	h := m.file.hex
+	_, height := m.viewerSize()
+	start, ok := h.missing(height)
+	if !ok {
+		return nil
+	}
+	h.pending = start
+	h.err = nil
+
+	req := m.fileLoad
+	return func() tea.Msg {
+		length := min(hexWindow, h.size-start)
+		data, generation, err := fetchRange(req.ctx, m.backend, h.bucketName, h.objectName, start, length)
+		return hexLoadedMsg{id: req.id, start: start, data: data, generation: generation, err: err}
+	}
+}
+
+// hexLoaded stores a fetched window unless the object changed since it was
+// opened. Failed fetches are retried when the cursor moves.
+func (m Model) hexLoaded(msg hexLoadedMsg) (Model, tea.Cmd) {
+	h := m.file.hex
+	if msg.id != m.fileLoad.id || h == nil || msg.start != h.pending {
+		return m, nil
+	}
+	h.pending = -1
+
+	var cmd tea.Cmd
+	switch {
+	case m.fileLoad.canceled():
+		return m, nil
+	case msg.err != nil:
+		h.err = msg.err
+	case msg.generation != h.generation:
+		h.err = fmt.Errorf("%s changed since it was opened, reopen it to see the new version", h.objectName)
+	default:
+		h.data = msg.data
+		h.start = msg.start
+		// The cursor may have moved on while the window was fetched
+		cmd = m.fetchHex()
+	}
+
+	if m.viewingFile && !m.querying {
+		m.statusMsg = m.viewerStatus()
+	}
+	return m, cmd
+}
+
+type hexLoadedMsg struct {
+	id         int
+	start      int64
+	data       []byte
+	generation string
+	err        error
+}
@@ -0,0 +1,199 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"text", "hello\nworld\n", false},
+		{"empty", "", false},
+		{"utf-8", "grüße, 世界\n", false},
+		{"utf-8 cut off in a character", "grüße, 世界"[:len("grüße, 世")-1], false},
+		{"terminal escapes", "\x1b[31mred\x1b[0m\n", false},
+		{"nul byte", "PK\x03\x04\x00\x00", true},
+		{"invalid utf-8", "\xff\xfe\xfd text", true},
+		{"control characters", strings.Repeat("\x01\x02ab", 10), true},
+	}
+	for _, tt := range tests {
+		if got := isBinary([]byte(tt.data)); got != tt.want {
+			t.Errorf("%s: isBinary = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "1024", want: 1024},
+		{input: " 0x400 ", want: 1024},
+		{input: "0X4_00", want: 1024},
+		{input: "1_000_000", want: 1000000},
+		{input: "0", want: 0},
+		{input: "", wantErr: true},
+		{input: "-1", wantErr: true},
+		{input: "0xzz", wantErr: true},
+		{input: "12k", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseOffset(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseOffset(%q) = %d, %v, want %d with error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// binaryData returns size bytes of binary content, each byte holding its
+// offset modulo 251 so that windows can be told apart
+func binaryData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+// goToOffset enters offset into the go to offset prompt of the hex view
+func goToOffset(m Model, offset string) Model {
+	m, _ = press(m, ":")
+	m.queryInput.SetValue(offset)
+	return pressAndRun(m, "enter")
+}
+
+// checkWindow checks that the hex view holds the object's bytes for every
+// visible row
+func checkWindow(t *testing.T, m Model, data []byte) {
+	t.Helper()
+	h := m.file.hex
+	_, height := m.viewerSize()
+	end := min(h.size, h.top+int64(height)*hexRowSize)
+	if h.start > h.top || h.start+int64(len(h.data)) < end {
+		t.Fatalf("window %d+%d does not cover the rows %d to %d", h.start, len(h.data), h.top, end)
+	}
+	if !bytes.Equal(h.data, data[h.start:h.start+int64(len(h.data))]) {
+		t.Errorf("window at %d does not hold the object's bytes there", h.start)
+	}
+}
+
+func TestHexWindows(t *testing.T) {
+	b := &countedRanges{Backend: testStore()}
+	data := binaryData(1 << 20)
+	b.PutObject("photos", "blob.bin", memory.Object{Data: data})
+	m := newTestModel(t, b, "photos")
+	m = pressAndRun(selectItem(t, m, "blob.bin"), "v")
+	h := m.file.hex
+	if h == nil {
+		t.Fatalf("blob.bin is not shown as a hex dump, status %q", m.statusMsg)
+	}
+	if len(h.data) > hexWindow {
+		t.Errorf("%d bytes are held, more than the window", len(h.data))
+	}
+	checkWindow(t, m, data)
+
+	// Moving within the window reads nothing
+	b.read.Store(0)
+	for _, k := range []string{"j", "j", "l"} {
+		var cmd tea.Cmd
+		if m, cmd = press(m, k); cmd != nil {
+			t.Errorf("%s fetched with the row loaded", k)
+		}
+	}
+	if h.cursor != 2*hexRowSize+1 {
+		t.Errorf("cursor at %d, want %d", h.cursor, 2*hexRowSize+1)
+	}
+
+	// Going elsewhere fetches only the window around the new rows
+	m = goToOffset(m, "0x80000")
+	if h.cursor != 0x80000 || h.top != 0x80000 {
+		t.Errorf("cursor at 0x%x with top row 0x%x, want both at 0x80000", h.cursor, h.top)
+	}
+	checkWindow(t, m, data)
+	if got := b.read.Load(); got != hexWindow {
+		t.Errorf("going to an offset read %d bytes, want a window of %d", got, hexWindow)
+	}
+	if !strings.Contains(m.statusMsg, "offset 0x80000 (524288), byte 0x") {
+		t.Errorf("status = %q, want the offset and its byte", m.statusMsg)
+	}
+
+	// The end of the object is fetched up to its last byte
+	m = pressAndRun(m, "G")
+	if h.cursor != int64(len(data))-1 {
+		t.Errorf("cursor at %d, want the last byte", h.cursor)
+	}
+	checkWindow(t, m, data)
+}
+
+func TestGoToOffsetErrors(t *testing.T) {
+	b := testStore()
+	b.PutObject("photos", "blob.bin", memory.Object{Data: binaryData(4096)})
+	m := pressAndRun(selectItem(t, newTestModel(t, b, "photos"), "blob.bin"), "v")
+	h := m.file.hex
+	if h == nil {
+		t.Fatalf("blob.bin is not shown as a hex dump, status %q", m.statusMsg)
+	}
+
+	tests := []struct {
+		offset string
+		status string
+	}{
+		{"zz", `Invalid offset: "zz" is not a byte offset`},
+		{"4096", "Offset 4096 is beyond the end of the object (4096 bytes)"},
+	}
+	for _, tt := range tests {
+		m = goToOffset(m, tt.offset)
+		if m.statusMsg != tt.status {
+			t.Errorf("going to %s: status = %q, want %q", tt.offset, m.statusMsg, tt.status)
+		}
+		if h.cursor != 0 || m.querying {
+			t.Errorf("going to %s moved the cursor to %d", tt.offset, h.cursor)
+		}
+	}
+
+	// Esc leaves the prompt without moving
+	m, _ = press(m, ":")
+	m.queryInput.SetValue("100")
+	m, _ = press(m, "esc")
+	if h.cursor != 0 || m.querying {
+		t.Errorf("esc moved the cursor to %d", h.cursor)
+	}
+
+	m = goToOffset(m, "4095")
+	if h.cursor != 4095 {
+		t.Errorf("cursor at %d, want the last byte", h.cursor)
+	}
+}
+
+func TestHexObjectChanged(t *testing.T) {
+	b := testStore()
+	b.PutObject("photos", "blob.bin", memory.Object{Data: binaryData(1 << 20)})
+	m := pressAndRun(selectItem(t, newTestModel(t, b, "photos"), "blob.bin"), "v")
+	h := m.file.hex
+	if h == nil {
+		t.Fatalf("blob.bin is not shown as a hex dump, status %q", m.statusMsg)
+	}
+	head := h.data
+
+	b.PutObject("photos", "blob.bin", memory.Object{Data: bytes.Repeat([]byte{0}, 1<<20)})
+	m = goToOffset(m, "0x80000")
+	if h.err == nil || !strings.Contains(h.err.Error(), "changed since it was opened") {
+		t.Errorf("error = %v, want the object to have changed", h.err)
+	}
+	if !bytes.Equal(h.data, head) || h.start != 0 {
+		t.Error("bytes of the new version were mixed into the view")
+	}
+	if !strings.Contains(m.statusMsg, "reopen it to see the new version") {
+		t.Errorf("status = %q, want it to say the object changed", m.statusMsg)
+	}
+}
@@ -0,0 +1,101 @@
+package ui
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// progressInterval is how often background jobs report progress
+const progressInterval = 150 * time.Millisecond
+
+// jobMsg carries a message from a background job together with the
+// channel its next message arrives on
+type jobMsg struct {
+	msg     tea.Msg
+	updates <-chan tea.Msg
+}
+
+// startJob runs work in the background. While it runs, progress is sampled
+// periodically and sent to the UI; the message returned by work comes last.
+func startJob(work func() tea.Msg, progress func() tea.Msg) tea.Cmd {
+	updates := make(chan tea.Msg)
+
+	go func() {
+		defer close(updates)
+
+		result := make(chan tea.Msg, 1)
+		go func() {
+			result <- work()
+		}()
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case msg := <-result:
+				updates <- msg
+				return
+			case <-ticker.C:
+				updates <- progress()
+			}
+		}
+	}()
+
+	return waitForJob(updates)
+}
+
+// waitForJob waits for the next message of a background job
+func waitForJob(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-updates
+		if !ok {
+			return nil
+		}
+		return jobMsg{msg: msg, updates: updates}
+	}
+}
+
+// forEach calls fn for every item using up to workers goroutines and stops
+// handing out items once ctx is cancelled. It returns the number of calls
+// that failed and the last error seen.
+func forEach[T any](ctx context.Context, workers int, items []T, fn func(T) error) (int, error) {
+	jobs := make(chan T)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed int
+	var lastErr error
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				if err := fn(item); err != nil {
+					mu.Lock()
+					failed++
+					lastErr = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case jobs <- item:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		lastErr = err
+	}
+	return failed, lastErr
+}
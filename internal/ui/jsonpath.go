@@ -0,0 +1,140 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonStep is a single step of a path expression
+type jsonStep struct {
+	// field selects an object member, index an array element and iterate
+	// every member or element
+	field   string
+	index   int
+	isField bool
+	iterate bool
+}
+
+// parseJSONPath parses a jq-style path expression such as .items[0].name,
+// .items[].id or .["a key"]. Only paths are supported, not filters or
+// functions.
+func parseJSONPath(expr string) ([]jsonStep, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, ".") {
+		return nil, fmt.Errorf("path must start with '.'")
+	}
+
+	var steps []jsonStep
+	for i := 0; i < len(expr); {
+		switch {
+		case expr[i] == '.' && i+1 < len(expr) && expr[i+1] == '"':
+			field, n, err := unquoteJSONPath(expr[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, jsonStep{field: field, isField: true})
+			i += 1 + n
+		case expr[i] == '.':
+			j := i + 1
+			for j < len(expr) && isJSONPathIdent(expr[j]) {
+				j++
+			}
+			if j > i+1 {
+				steps = append(steps, jsonStep{field: expr[i+1 : j], isField: true})
+			}
+			i = j
+		case expr[i] == '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if strings.HasPrefix(expr[i+1:], `"`) {
+				field, n, err := unquoteJSONPath(expr[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				if !strings.HasPrefix(expr[i+1+n:], "]") {
+					return nil, fmt.Errorf("missing ']' at %d", i+1+n)
+				}
+				steps = append(steps, jsonStep{field: field, isField: true})
+				i += 2 + n
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' at %d", i)
+			}
+			inner := strings.TrimSpace(expr[i+1 : i+end])
+			if inner == "" {
+				steps = append(steps, jsonStep{iterate: true})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q", inner)
+				}
+				steps = append(steps, jsonStep{index: index})
+			}
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", expr[i], i)
+		}
+	}
+	return steps, nil
+}
+
+// unquoteJSONPath unquotes the JSON string at the start of s and returns
+// it with the number of bytes it took up
+func unquoteJSONPath(s string) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			field, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid key %s", s[:i+1])
+			}
+			return field, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated key %s", s)
+}
+
+func isJSONPathIdent(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// evalJSONPath evaluates steps against every document, like jq does for a
+// stream of values. Values a step does not apply to, such as missing
+// members, are skipped.
+func evalJSONPath(docs []*jsonNode, steps []jsonStep) []*jsonNode {
+	values := docs
+	for _, step := range steps {
+		var next []*jsonNode
+		for _, value := range values {
+			switch {
+			case step.iterate:
+				next = append(next, value.children...)
+			case step.isField:
+				if value.kind != jsonObject {
+					continue
+				}
+				for _, child := range value.children {
+					if child.key == step.field {
+						next = append(next, child)
+					}
+				}
+			default:
+				if value.kind != jsonArray {
+					continue
+				}
+				index := step.index
+				if index < 0 {
+					index += len(value.children)
+				}
+				if index >= 0 && index < len(value.children) {
+					next = append(next, value.children[index])
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	field := func(name string) jsonStep { return jsonStep{field: name, isField: true} }
+	index := func(i int) jsonStep { return jsonStep{index: i} }
+	iterate := jsonStep{iterate: true}
+
+	tests := []struct {
+		expr    string
+		want    []jsonStep
+		wantErr string
+	}{
+		{expr: ".", want: nil},
+		{expr: " .name ", want: []jsonStep{field("name")}},
+		{expr: ".items[0].name", want: []jsonStep{field("items"), index(0), field("name")}},
+		{expr: ".items[-1]", want: []jsonStep{field("items"), index(-1)}},
+		{expr: ".items[].id", want: []jsonStep{field("items"), iterate, field("id")}},
+		{expr: ".[]", want: []jsonStep{iterate}},
+		{expr: ".[ 2 ]", want: []jsonStep{index(2)}},
+		{expr: `.["a key"]`, want: []jsonStep{field("a key")}},
+		{expr: `."a.b".c`, want: []jsonStep{field("a.b"), field("c")}},
+		{expr: `.a["q\"uote"]`, want: []jsonStep{field("a"), field(`q"uote`)}},
+		{expr: ".$id_2", want: []jsonStep{field("$id_2")}},
+
+		{expr: "", wantErr: "must start with '.'"},
+		{expr: "items", wantErr: "must start with '.'"},
+		{expr: ".items[0", wantErr: "missing ']'"},
+		{expr: `.["a"`, wantErr: "missing ']'"},
+		{expr: ".items[x]", wantErr: `invalid index "x"`},
+		{expr: `.["unterminated]`, wantErr: "unterminated key"},
+		{expr: `.["bad \q"]`, wantErr: "invalid key"},
+		{expr: ".a-b", wantErr: `unexpected '-'`},
+		{expr: ".a | length", wantErr: `unexpected ' '`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := parseJSONPath(tt.expr)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("steps = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// jsonValues summarises nodes as their scalar values, and objects and
+// arrays as their brackets around a dot per child
+func jsonValues(nodes []*jsonNode) []string {
+	var values []string
+	for _, node := range nodes {
+		switch node.kind {
+		case jsonObject:
+			values = append(values, "{"+strings.Repeat(".", len(node.children))+"}")
+		case jsonArray:
+			values = append(values, "["+strings.Repeat(".", len(node.children))+"]")
+		default:
+			values = append(values, node.value)
+		}
+	}
+	return values
+}
+
+func TestEvalJSONPath(t *testing.T) {
+	document := `{"name": "fleet", "items": [{"id": 1, "tags": ["a", "b"]}, {"id": 2}, {"id": 3, "tags": []}], "a key": true}`
+	records := `{"id": 1, "user": "ada", "tags": ["x"]}
+{"id": 2, "tags": ["y", "z"]}
+[4, 5]
+"scalar"
+{"id": 3, "user": "cy"}
+`
+
+	tests := []struct {
+		name    string
+		content string
+		expr    string
+		want    []string
+	}{
+		{"identity", document, ".", []string{"{...}"}},
+		{"field", document, ".name", []string{`"fleet"`}},
+		{"quoted field", document, `.["a key"]`, []string{"true"}},
+		{"missing field", document, ".missing", nil},
+		{"index", document, ".items[1].id", []string{"2"}},
+		{"negative index", document, ".items[-1].id", []string{"3"}},
+		{"index out of range", document, ".items[3]", nil},
+		{"field of an array", document, ".items.id", nil},
+		{"index of an object", document, ".[0]", nil},
+		{"iterate array", document, ".items[].id", []string{"1", "2", "3"}},
+		{"iterate nested", document, ".items[].tags[]", []string{`"a"`, `"b"`}},
+		{"iterate object", document, ".items[0][]", []string{"1", "[..]"}},
+		{"iterate scalar", document, ".name[]", nil},
+
+		{"every record", records, ".", []string{"{...}", "{..}", "[..]", `"scalar"`, "{..}"}},
+		{"field of every record", records, ".id", []string{"1", "2", "3"}},
+		{"field some records lack", records, ".user", []string{`"ada"`, `"cy"`}},
+		{"iterate every record", records, ".[]", []string{"1", `"ada"`, "[.]", "2", "[..]", "4", "5", "3", `"cy"`}},
+		{"iterate a field of every record", records, ".tags[]", []string{`"x"`, `"y"`, `"z"`}},
+		{"index of every record", records, ".tags[0]", []string{`"x"`, `"y"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := parseJSON(tt.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			steps, err := parseJSONPath(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := jsonValues(evalJSONPath(docs, steps)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,573 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	jsonKeyStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#4A86CF"))
+	jsonStringStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#4ACF6B"))
+	jsonNumberStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#B48EAD"))
+	jsonLiteralStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#CFA84A"))
+	jsonDimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#727272"))
+	jsonCursorStyle  = lipgloss.NewStyle().Background(lipgloss.Color("#3C3C3C"))
+)
+
+type jsonKind int
+
+const (
+	jsonScalar jsonKind = iota
+	jsonObject
+	jsonArray
+)
+
+// jsonNode is a value of a parsed JSON document. Object members keep the
+// order they appear in.
+type jsonNode struct {
+	key      string
+	hasKey   bool
+	kind     jsonKind
+	value    string
+	children []*jsonNode
+	folded   bool
+}
+
+// isJSON reports whether an object should open in the JSON viewer
+func isJSON(name, contentType string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json", ".ndjson", ".jsonl", ".geojson":
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || mediaType == "application/x-ndjson" ||
+		strings.HasSuffix(mediaType, "+json")
+}
+
+// parseJSON parses a JSON document, or every record of newline-delimited
+// JSON
+func parseJSON(content string) ([]*jsonNode, error) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.UseNumber()
+
+	var docs []*jsonNode
+	for {
+		node, err := decodeJSONNode(dec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON at byte %d: %v", dec.InputOffset(), err)
+		}
+		docs = append(docs, node)
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("no JSON values found")
+	}
+	return docs, nil
+}
+
+// decodeJSONNode decodes the next value from dec
+func decodeJSONNode(dec *json.Decoder) (*jsonNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		node := &jsonNode{kind: jsonObject}
+		if t == '[' {
+			node.kind = jsonArray
+		}
+		for dec.More() {
+			var key string
+			if node.kind == jsonObject {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ = keyTok.(string)
+			}
+			child, err := decodeJSONNode(dec)
+			if errors.Is(err, io.EOF) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			child.key, child.hasKey = key, node.kind == jsonObject
+			node.children = append(node.children, child)
+		}
+		// Consume the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &jsonNode{value: jsonQuote(t)}, nil
+	case json.Number:
+		return &jsonNode{value: t.String()}, nil
+	case bool:
+		return &jsonNode{value: strconv.FormatBool(t)}, nil
+	default:
+		return &jsonNode{value: "null"}, nil
+	}
+}
+
+// jsonQuote quotes s as a JSON string
+func jsonQuote(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// setFolded folds or unfolds node and every container below it
+func (n *jsonNode) setFolded(folded bool) {
+	if n.kind == jsonScalar {
+		return
+	}
+	n.folded = folded
+	for _, child := range n.children {
+		child.setFolded(folded)
+	}
+}
+
+// jsonLine is a line of the rendered tree: a record header, a value, or
+// the closing bracket of an unfolded container
+type jsonLine struct {
+	node    *jsonNode
+	depth   int
+	header  int
+	closing bool
+	last    bool
+}
+
+// jsonView shows JSON documents, or the results of a path expression, as a
+// tree with foldable objects and arrays
+type jsonView struct {
+	docs  []*jsonNode
+	roots []*jsonNode
+	query string
+
+	lines  []jsonLine
+	starts []int
+	cursor int
+	offset int
+
+	// search holds the matches of the last search in the rendered lines
+	search *textSearch
+}
+
+// newJSONView creates a view of parsed documents
+func newJSONView(docs []*jsonNode) *jsonView {
+	v := &jsonView{docs: docs, roots: docs}
+	v.layout()
+	return v
+}
+
+// records reports whether the view holds more than one top-level value
+func (v *jsonView) records() bool {
+	return len(v.roots) > 1
+}
+
+// layout lists the visible lines and where each top-level value starts
+func (v *jsonView) layout() {
+	v.lines = v.lines[:0]
+	v.starts = v.starts[:0]
+	for i, root := range v.roots {
+		v.starts = append(v.starts, len(v.lines))
+		if v.records() {
+			v.lines = append(v.lines, jsonLine{node: root, header: i + 1})
+		}
+		v.lines = appendJSONLines(v.lines, root, 0, true)
+	}
+	v.cursor = max(0, min(v.cursor, len(v.lines)-1))
+
+	// Folding and path expressions change the lines, and so the matches
+	if v.search != nil {
+		v.search = v.search.redo(v.plainLines(), v.cursor)
+	}
+}
+
+// plainLines renders the lines of the tree without colours
+func (v *jsonView) plainLines() []string {
+	lines := make([]string, len(v.lines))
+	for i, line := range v.lines {
+		lines[i] = renderJSONLine(line, false)
+	}
+	return lines
+}
+
+// find searches the rendered lines for pattern and moves the cursor to the
+// first match at or below it. An empty pattern clears the search.
+func (v *jsonView) find(pattern string, ignoreCase bool, height int) error {
+	if pattern == "" {
+		v.search = nil
+		return nil
+	}
+	search, err := newTextSearch(pattern, ignoreCase, v.plainLines())
+	if err != nil {
+		return err
+	}
+	search.seek(v.cursor)
+	v.search = search
+	v.showMatch(height)
+	return nil
+}
+
+// step moves to the next match, or the previous one if delta is negative
+func (v *jsonView) step(delta, height int) {
+	v.search.step(delta)
+	v.showMatch(height)
+}
+
+// showMatch moves the cursor to the line of the current match
+func (v *jsonView) showMatch(height int) {
+	if s := v.search; s != nil && len(s.matches) > 0 {
+		v.cursor = s.matches[s.current].line
+		v.scroll(height)
+	}
+}
+
+func appendJSONLines(lines []jsonLine, node *jsonNode, depth int, last bool) []jsonLine {
+	lines = append(lines, jsonLine{node: node, depth: depth, last: last})
+	if node.kind == jsonScalar || node.folded || len(node.children) == 0 {
+		return lines
+	}
+	for i, child := range node.children {
+		lines = appendJSONLines(lines, child, depth+1, i == len(node.children)-1)
+	}
+	return append(lines, jsonLine{node: node, depth: depth, closing: true, last: last})
+}
+
+// record returns the index of the top-level value the cursor is in
+func (v *jsonView) record() int {
+	record := 0
+	for i, start := range v.starts {
+		if start <= v.cursor {
+			record = i
+		}
+	}
+	return record
+}
+
+// move moves the cursor by delta lines, keeping it within a window of
+// height lines
+func (v *jsonView) move(delta, height int) {
+	v.cursor = max(0, min(v.cursor+delta, len(v.lines)-1))
+	v.scroll(height)
+}
+
+// scroll adjusts the window so that the cursor stays in view
+func (v *jsonView) scroll(height int) {
+	height = max(1, height)
+	if v.cursor < v.offset {
+		v.offset = v.cursor
+	}
+	if v.cursor >= v.offset+height {
+		v.offset = v.cursor - height + 1
+	}
+	v.offset = max(0, min(v.offset, len(v.lines)-height))
+}
+
+// jumpRecord moves the cursor to the start of the next or previous
+// top-level value
+func (v *jsonView) jumpRecord(delta, height int) {
+	record := max(0, min(v.record()+delta, len(v.starts)-1))
+	v.cursor = v.starts[record]
+	v.offset = v.cursor
+	v.scroll(height)
+}
+
+// toggle folds or unfolds the container under the cursor
+func (v *jsonView) toggle(height int) {
+	if len(v.lines) == 0 {
+		return
+	}
+	node := v.lines[v.cursor].node
+	if node.kind == jsonScalar || len(node.children) == 0 {
+		return
+	}
+	closing := v.lines[v.cursor].closing
+	node.folded = !node.folded
+	v.layout()
+
+	// Keep the cursor on the container when folding from its closing line
+	if closing {
+		for i := v.cursor; i >= 0; i-- {
+			if v.lines[i].node == node && !v.lines[i].closing && v.lines[i].header == 0 {
+				v.cursor = i
+				break
+			}
+		}
+	}
+	v.scroll(height)
+}
+
+// setFoldedAll folds or unfolds every container
+func (v *jsonView) setFoldedAll(folded bool, height int) {
+	var current jsonLine
+	if len(v.lines) > 0 {
+		current = v.lines[v.cursor]
+	}
+	for _, root := range v.roots {
+		root.setFolded(folded)
+		// Keep top-level values open so that their members stay visible
+		if folded && !v.records() {
+			root.folded = false
+		}
+	}
+	v.layout()
+	// Record headers share their record's node
+	for i, line := range v.lines {
+		if line.node == current.node && !line.closing && line.header == current.header {
+			v.cursor = i
+			break
+		}
+	}
+	v.scroll(height)
+}
+
+// showResults replaces the tree with the results of a path expression
+func (v *jsonView) showResults(query string, results []*jsonNode, height int) {
+	v.query = query
+	v.roots = results
+	v.cursor, v.offset = 0, 0
+	v.layout()
+	v.scroll(height)
+}
+
+// clearResults returns from path expression results to the documents
+func (v *jsonView) clearResults(height int) {
+	v.showResults("", v.docs, height)
+}
+
+// view renders the window of lines starting at the scroll offset
+func (v *jsonView) view(width, height int) string {
+	lines := make([]string, 0, height)
+	for i := v.offset; i < len(v.lines) && len(lines) < height; i++ {
+		line := renderJSONLine(v.lines[i], true)
+		if v.search != nil {
+			if marked, ok := v.search.markLine(i, renderJSONLine(v.lines[i], false)); ok {
+				line = marked
+			}
+		}
+		if i == v.cursor {
+			line = jsonCursorStyle.Render(line)
+		}
+		lines = append(lines, lipgloss.NewStyle().MaxWidth(width).Render(line))
+	}
+	if len(v.lines) == 0 {
+		lines = append(lines, jsonDimStyle.Render("No results"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderJSONLine renders a line of the tree, coloured if styled is set
+func renderJSONLine(line jsonLine, styled bool) string {
+	paint := func(style lipgloss.Style, s string) string {
+		if !styled {
+			return s
+		}
+		return style.Render(s)
+	}
+
+	node := line.node
+	if line.header > 0 {
+		return paint(jsonDimStyle, fmt.Sprintf("#%d", line.header))
+	}
+
+	var s strings.Builder
+	s.WriteString(strings.Repeat("  ", line.depth))
+
+	open, close := "{", "}"
+	if node.kind == jsonArray {
+		open, close = "[", "]"
+	}
+
+	if line.closing {
+		s.WriteString("  " + close)
+	} else {
+		switch {
+		case node.kind == jsonScalar || len(node.children) == 0:
+			s.WriteString("  ")
+		case node.folded:
+			s.WriteString("▸ ")
+		default:
+			s.WriteString("▾ ")
+		}
+		if node.hasKey {
+			s.WriteString(paint(jsonKeyStyle, jsonQuote(node.key)) + ": ")
+		}
+
+		switch {
+		case node.kind == jsonScalar:
+			s.WriteString(paint(jsonScalarStyle(node.value), node.value))
+		case len(node.children) == 0:
+			s.WriteString(open + close)
+		case node.folded:
+			unit := "items"
+			if node.kind == jsonObject {
+				unit = "keys"
+			}
+			s.WriteString(open + "…" + close + paint(jsonDimStyle, fmt.Sprintf(" %d %s", len(node.children), unit)))
+		default:
+			s.WriteString(open)
+			return s.String()
+		}
+	}
+
+	if !line.last {
+		s.WriteString(",")
+	}
+	return s.String()
+}
+
+// jsonScalarStyle returns the colour of a scalar by its type
+func jsonScalarStyle(value string) lipgloss.Style {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return jsonStringStyle
+	case value == "true" || value == "false" || value == "null":
+		return jsonLiteralStyle
+	}
+	return jsonNumberStyle
+}
+
+// jsonStatus describes the JSON tree and the record under the cursor
+func (m Model) jsonStatus() string {
+	tree := m.file.tree
+	status := fmt.Sprintf("Viewing %s (JSON tree", m.file.name)
+	if tree.query != "" {
+		unit := "results"
+		if len(tree.roots) == 1 {
+			unit = "result"
+		}
+		status += fmt.Sprintf(", %d %s for %s, esc: back to document", len(tree.roots), unit, tree.query)
+	}
+	if tree.records() {
+		status += fmt.Sprintf(", record %d of %d", tree.record()+1, len(tree.roots))
+	}
+	return status + ")"
+}
+
+// updateJSONView handles keys while the JSON tree is shown
+func (m Model) updateJSONView(msg tea.KeyMsg) (Model, tea.Cmd) {
+	tree := m.file.tree
+	_, height := m.viewerSize()
+
+	switch {
+	case key.Matches(msg, m.keyMap.Quit):
+		return m.quit()
+	case key.Matches(msg, m.keyMap.Cancel) && tree.search != nil:
+		// Clear the highlighted matches
+		tree.search = nil
+	case tree.query != "" && (msg.String() == "esc" || key.Matches(msg, m.keyMap.Back)):
+		tree.clearResults(height)
+	case key.Matches(msg, m.keyMap.Back):
+		m.viewingFile = false
+		return m, nil
+	case key.Matches(msg, m.keyMap.Structured):
+		m.file.structured = false
+	case key.Matches(msg, m.keyMap.Search):
+		return m.startSearch()
+	case key.Matches(msg, m.keyMap.NextMatch), key.Matches(msg, m.keyMap.PrevMatch):
+		if tree.search == nil {
+			m.statusMsg = "Nothing searched yet, press / to search"
+			return m, nil
+		}
+		delta := 1
+		if key.Matches(msg, m.keyMap.PrevMatch) {
+			delta = -1
+		}
+		tree.step(delta, height)
+	case key.Matches(msg, m.keyMap.Query):
+		m.querying = true
+		m.queryInput = textinput.New()
+		m.queryInput.Prompt = "Path: "
+		m.queryInput.Placeholder = ".items[].name"
+		m.queryInput.SetValue(tree.query)
+		m.queryInput.CursorEnd()
+		return m, m.queryInput.Focus()
+	default:
+		switch msg.String() {
+		case "up", "k":
+			tree.move(-1, height)
+		case "down", "j":
+			tree.move(1, height)
+		case "pgup", "ctrl+u":
+			tree.move(-height, height)
+		case "pgdown", "ctrl+d":
+			tree.move(height, height)
+		case "home", "g":
+			tree.move(-len(tree.lines), height)
+		case "end", "G":
+			tree.move(len(tree.lines), height)
+		case "enter", " ":
+			tree.toggle(height)
+		case "-":
+			tree.setFoldedAll(true, height)
+		case "+", "=":
+			tree.setFoldedAll(false, height)
+		case "]":
+			tree.jumpRecord(1, height)
+		case "[":
+			tree.jumpRecord(-1, height)
+		default:
+			return m, nil
+		}
+	}
+
+	m.statusMsg = m.viewerStatus()
+	return m, nil
+}
+
+// updateQuery handles the JSON path prompt
+func (m Model) updateQuery(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "esc":
+		m.querying = false
+		m.statusMsg = m.viewerStatus()
+		return m, nil
+	case "enter":
+		m.querying = false
+		expr := strings.TrimSpace(m.queryInput.Value())
+		_, height := m.viewerSize()
+		if expr == "" || expr == "." {
+			m.file.tree.clearResults(height)
+			m.statusMsg = m.viewerStatus()
+			return m, nil
+		}
+
+		steps, err := parseJSONPath(expr)
+		if err != nil {
+			m.statusMsg = fmt.Sprintf("Invalid path: %v", err)
+			return m, nil
+		}
+		m.file.structured = true
+		m.file.tree.showResults(expr, evalJSONPath(m.file.tree.docs, steps), height)
+		m.statusMsg = m.viewerStatus()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.queryInput, cmd = m.queryInput.Update(msg)
+	return m, cmd
+}
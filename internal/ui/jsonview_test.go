@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonTestView parses content into a view
+func jsonTestView(t *testing.T, content string) *jsonView {
+	t.Helper()
+	docs, err := parseJSON(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newJSONView(docs)
+}
+
+// trimmedLines renders the lines of a view without colours or indentation
+func trimmedLines(v *jsonView) []string {
+	var lines []string
+	for _, line := range v.plainLines() {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return lines
+}
+
+func TestJumpRecord(t *testing.T) {
+	// Each record takes a header, its braces and two members
+	records := `{"id": 1, "ok": true}
+{"id": 2, "ok": false}
+{"id": 3, "ok": true}
+`
+	tests := []struct {
+		name    string
+		content string
+		cursor  int
+		delta   int
+		want    int
+	}{
+		{"next from the first header", records, 0, 1, 5},
+		{"next from inside a record", records, 7, 1, 10},
+		{"next from the last record", records, 12, 1, 10},
+		{"previous from inside a record", records, 7, -1, 0},
+		{"previous from a header", records, 10, -1, 5},
+		{"previous from the first record", records, 2, -1, 0},
+		{"several records at once", records, 0, 5, 10},
+		{"single document", `{"a": [1, 2]}`, 3, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := jsonTestView(t, tt.content)
+			v.cursor = tt.cursor
+			v.jumpRecord(tt.delta, 3)
+			if v.cursor != tt.want {
+				t.Errorf("cursor = %d, want %d", v.cursor, tt.want)
+			}
+			if v.offset > v.cursor || v.cursor >= v.offset+3 {
+				t.Errorf("cursor %d is outside the window at %d", v.cursor, v.offset)
+			}
+		})
+	}
+}
+
+func TestSetFoldedAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// cursor is on a container that stays visible when folded
+		cursor int
+		folded []string
+	}{
+		{
+			name:    "document keeps its members visible",
+			content: `{"a": {"b": 1}, "c": [1, 2], "d": 3}`,
+			cursor:  4,
+			folded:  []string{"▾ {", `▸ "a": {…} 1 keys,`, `▸ "c": […] 2 items,`, `"d": 3`, "}"},
+		},
+		{
+			name:    "records fold entirely",
+			content: "{\"id\": 1, \"tags\": [\"x\"]}\n{\"id\": 2}\n",
+			cursor:  8,
+			folded:  []string{"#1", "▸ {…} 2 keys", "#2", "▸ {…} 1 keys"},
+		},
+		{
+			name:    "record header",
+			content: "{\"id\": 1, \"tags\": [\"x\"]}\n{\"id\": 2}\n",
+			cursor:  7,
+			folded:  []string{"#1", "▸ {…} 2 keys", "#2", "▸ {…} 1 keys"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := jsonTestView(t, tt.content)
+			unfolded := trimmedLines(v)
+			v.cursor = tt.cursor
+			want := v.lines[v.cursor]
+
+			v.setFoldedAll(true, 10)
+			if got := trimmedLines(v); !reflect.DeepEqual(got, tt.folded) {
+				t.Errorf("folded lines = %q, want %q", got, tt.folded)
+			}
+			if line := v.lines[v.cursor]; line.node != want.node || line.header != want.header || line.closing {
+				t.Errorf("folding moved the cursor to %q", trimmedLines(v)[v.cursor])
+			}
+
+			v.setFoldedAll(false, 10)
+			if got := trimmedLines(v); !reflect.DeepEqual(got, unfolded) {
+				t.Errorf("unfolded lines = %q, want %q", got, unfolded)
+			}
+			if v.cursor != tt.cursor {
+				t.Errorf("unfolding moved the cursor to %q", trimmedLines(v)[v.cursor])
+			}
+		})
+	}
+}
@@ -1,19 +1,27 @@
 package ui
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/filepicker"
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/fernandoabolafio/lazybucket/internal/gcs"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/config"
+	"github.com/fernandoabolafio/lazybucket/internal/transfer"
 )
 
 // Styling
@@ -67,20 +75,47 @@ var (
 	copyMessageStyle = lipgloss.NewStyle().
 				Foreground(lipgloss.Color("#00FF00")).
 				Render
+
+	pickerHeaderStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#4A86CF"))
 )
 
 // KeyMap defines the keybindings for the application
 type KeyMap struct {
-	Up       key.Binding
-	Down     key.Binding
-	Enter    key.Binding
-	Back     key.Binding
-	Quit     key.Binding
-	View     key.Binding
-	Help     key.Binding
-	Refresh  key.Binding
-	Download key.Binding
-	CopyURL  key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	Enter      key.Binding
+	Back       key.Binding
+	Quit       key.Binding
+	View       key.Binding
+	Help       key.Binding
+	Refresh    key.Binding
+	Download   key.Binding
+	DownloadTo key.Binding
+	Decompress key.Binding
+	Upload     key.Binding
+	Delete     key.Binding
+	StopDelete key.Binding
+	Select     key.Binding
+	SelectAll  key.Binding
+	Invert     key.Binding
+	Yank       key.Binding
+	Paste      key.Binding
+	PasteMove  key.Binding
+	Rename     key.Binding
+	CopyURL    key.Binding
+	Transfers  key.Binding
+	Cancel     key.Binding
+
+	// Viewer keybindings
+	Highlight   key.Binding
+	LineNumbers key.Binding
+	Structured  key.Binding
+	Query       key.Binding
+	Search      key.Binding
+	NextMatch   key.Binding
+	PrevMatch   key.Binding
 }
 
 // DefaultKeyMap returns the default keybindings
@@ -120,18 +155,102 @@ func DefaultKeyMap() KeyMap {
 		),
 		Download: key.NewBinding(
 			key.WithKeys("d"),
-			key.WithHelp("d", "download file"),
+			key.WithHelp("d", "download"),
+		),
+		DownloadTo: key.NewBinding(
+			key.WithKeys("D"),
+			key.WithHelp("D", "download to..."),
+		),
+		Decompress: key.NewBinding(
+			key.WithKeys("z"),
+			key.WithHelp("z", "download decompressed"),
+		),
+		Upload: key.NewBinding(
+			key.WithKeys("u"),
+			key.WithHelp("u", "upload"),
+		),
+		Delete: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", "delete"),
+		),
+		StopDelete: key.NewBinding(
+			key.WithKeys("X"),
+			key.WithHelp("X", "stop delete"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "mark"),
+		),
+		SelectAll: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "mark all"),
+		),
+		Invert: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("i", "invert marks"),
+		),
+		Yank: key.NewBinding(
+			key.WithKeys("y"),
+			key.WithHelp("y", "yank for copy"),
+		),
+		Paste: key.NewBinding(
+			key.WithKeys("p"),
+			key.WithHelp("p", "paste copy"),
+		),
+		PasteMove: key.NewBinding(
+			key.WithKeys("P"),
+			key.WithHelp("P", "paste move"),
+		),
+		Rename: key.NewBinding(
+			key.WithKeys("R"),
+			key.WithHelp("R", "rename"),
 		),
 		CopyURL: key.NewBinding(
 			key.WithKeys("c"),
-			key.WithHelp("c", "copy gsutil URL"),
+			key.WithHelp("c", "copy object URL"),
+		),
+		Transfers: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "transfers"),
+		),
+		Highlight: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "toggle syntax highlighting"),
+		),
+		LineNumbers: key.NewBinding(
+			key.WithKeys("#"),
+			key.WithHelp("#", "toggle line numbers"),
+		),
+		Structured: key.NewBinding(
+			key.WithKeys("J"),
+			key.WithHelp("J", "toggle tree/table view"),
+		),
+		Query: key.NewBinding(
+			key.WithKeys(":"),
+			key.WithHelp(":", "JSON path or offset"),
+		),
+		Cancel: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "cancel"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "search"),
+		),
+		NextMatch: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "next match"),
+		),
+		PrevMatch: key.NewBinding(
+			key.WithKeys("N"),
+			key.WithHelp("N", "previous match"),
 		),
 	}
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view
 func (k KeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.Up, k.Down, k.Enter, k.Back, k.View, k.Download, k.CopyURL, k.Help, k.Quit}
+	return []key.Binding{k.Up, k.Down, k.Enter, k.Back, k.View, k.Download, k.Upload, k.Delete, k.CopyURL, k.Help, k.Quit}
 }
 
 // FullHelp returns keybindings for the expanded help view
@@ -139,14 +258,20 @@ func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down, k.Enter},
 		{k.Back, k.View, k.Refresh},
-		{k.Download, k.CopyURL},
+		{k.Download, k.DownloadTo, k.Decompress, k.Upload, k.Delete, k.StopDelete},
+		{k.Select, k.SelectAll, k.Invert},
+		{k.Yank, k.Paste, k.PasteMove, k.Rename},
+		{k.CopyURL, k.Transfers, k.Cancel},
+		{k.Highlight, k.LineNumbers, k.Structured, k.Query},
+		{k.Search, k.NextMatch, k.PrevMatch},
 		{k.Help, k.Quit},
 	}
 }
 
 // ListItem represents an item in the list
 type ListItem struct {
-	item gcs.Item
+	item   backend.Item
+	marked bool
 }
 
 // FilterValue implements list.Item interface
@@ -154,8 +279,15 @@ func (i ListItem) FilterValue() string {
 	return i.item.Name
 }
 
-// Title returns the item name
+// Title returns the item name, flagged when the item is marked
 func (i ListItem) Title() string {
+	if i.marked {
+		return "✔ " + i.title()
+	}
+	return i.title()
+}
+
+func (i ListItem) title() string {
 	if i.item.IsDir {
 		if i.item.Name == ".." {
 			return "📁 .."
@@ -165,6 +297,9 @@ func (i ListItem) Title() string {
 		}
 		return "📁 " + i.item.Name
 	}
+	if archiveFormat(i.item.Name) != "" {
+		return "📦 " + i.item.Name
+	}
 	return "📄 " + i.item.Name
 }
 
@@ -178,18 +313,53 @@ func (i ListItem) Description() string {
 
 // Model represents the application state
 type Model struct {
-	gcsClient        *gcs.Client
+	backend          backend.Backend
+	options          Options
 	list             list.Model
 	help             help.Model
 	viewport         viewport.Model
 	keyMap           KeyMap
 	currentPath      string
 	pathHistory      []string
+	archive          *archive
 	statusMsg        string
 	showHelp         bool
 	viewingFile      bool
-	fileContent      string
+	file             fileView
 	loadingItems     bool
+	loadingMore      bool
+	nextPageToken    string
+	reloadStatus     string
+	listing          request
+	fileLoad         request
+	planning         request
+	deleteJob        request
+	picker           filepicker.Model
+	pickingUpload    bool
+	confirmDelete    *deletePlan
+	deleting         bool
+	deletion         deleteProgressMsg
+	clipboard        []backend.Item
+	queue            *transfer.Manager
+	showTransfers    bool
+	transferCursor   int
+	watching         bool
+	refreshPath      string
+	batch            transferBatch
+	confirmDownload  *downloadPlan
+	confirmCopy      *copyPlanMsg
+	confirmQuit      bool
+	choosingDir      bool
+	downloadItems    []backend.Item
+	dirInput         textinput.Model
+	renaming         bool
+	renameItem       backend.Item
+	renameInput      textinput.Model
+	querying         bool
+	searching        bool
+	ignoreCase       bool
+	queryInput       textinput.Model
+	progressBar      progress.Model
 	ready            bool
 	width            int
 	height           int
@@ -197,8 +367,23 @@ type Model struct {
 	copyMessageTimer int
 }
 
-// New creates a new UI model
-func New(gcsClient *gcs.Client) Model {
+// New creates a new UI model browsing the given backend, starting at
+// startPath ("" for the bucket list)
+func New(b backend.Backend, startPath string, opts Options) Model {
+	defaults := DefaultOptions()
+	if opts.DownloadDir == "" {
+		opts.DownloadDir = defaults.DownloadDir
+	}
+	if opts.Workers < 1 {
+		opts.Workers = defaults.Workers
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = defaults.Conflict
+	}
+	if opts.ViewerWindow < 1 {
+		opts.ViewerWindow = defaults.ViewerWindow
+	}
+
 	// Create list
 	delegate := list.NewDefaultDelegate()
 	listModel := list.New([]list.Item{}, delegate, 0, 0)
@@ -218,18 +403,24 @@ func New(gcsClient *gcs.Client) Model {
 
 	// Create model
 	m := Model{
-		gcsClient:        gcsClient,
+		backend:          b,
+		options:          opts,
 		list:             listModel,
 		help:             helpModel,
 		viewport:         viewportModel,
 		keyMap:           DefaultKeyMap(),
-		currentPath:      "",
-		pathHistory:      []string{},
+		currentPath:      strings.Trim(startPath, "/"),
+		pathHistory:      ancestors(startPath),
 		statusMsg:        "Loading...",
 		showHelp:         false,
 		viewingFile:      false,
-		fileContent:      "",
 		loadingItems:     true,
+		listing:          newRequest(),
+		fileLoad:         newRequest(),
+		planning:         newRequest(),
+		deleteJob:        newRequest(),
+		queue:            transfer.NewManager(opts.Workers),
+		progressBar:      progress.New(progress.WithDefaultGradient()),
 		ready:            false,
 		showCopyMessage:  false,
 		copyMessageTimer: 0,
@@ -246,25 +437,146 @@ func (m Model) Init() tea.Cmd {
 	)
 }
 
-// loadItems loads items from the current path
+// loadItems loads the first page of items from the current path
 func (m Model) loadItems() tea.Cmd {
+	return m.loadPage("")
+}
+
+// loadMoreItems loads the next page of items from the current path
+func (m Model) loadMoreItems() tea.Cmd {
+	return m.loadPage(m.nextPageToken)
+}
+
+// loadPage loads the page of items starting at pageToken as part of the
+// current listing request. Results of a cancelled listing are dropped.
+func (m Model) loadPage(pageToken string) tea.Cmd {
+	currentPath := m.currentPath
+	req := m.listing
+	a := m.archive
 	return func() tea.Msg {
-		if m.currentPath == "" {
+		if a != nil {
+			// Archives are listed from their index in one page
+			items, err := a.list(req.ctx, m.backend, currentPath)
+			if req.canceled() {
+				return nil
+			}
+			if err != nil {
+				return errMsg{err}
+			}
+			return itemsLoadedMsg{items: items, id: req.id}
+		}
+
+		if currentPath == "" {
 			// Load buckets
-			items, err := m.gcsClient.ListBuckets()
+			items, err := m.backend.ListBuckets(req.ctx)
+			if req.canceled() {
+				return nil
+			}
 			if err != nil {
 				return errMsg{err}
 			}
-			return itemsLoadedMsg{items}
+			return itemsLoadedMsg{items: items, id: req.id}
 		}
 
 		// Load objects from bucket/prefix
-		bucketName, prefix := gcs.ParsePath(m.currentPath)
-		items, err := m.gcsClient.ListObjects(bucketName, prefix)
+		bucketName, prefix := backend.ParsePath(currentPath)
+		items, nextPageToken, err := m.backend.ListObjects(req.ctx, bucketName, prefix, pageToken, backend.DefaultPageSize)
+		if req.canceled() {
+			return nil
+		}
 		if err != nil {
 			return errMsg{err}
 		}
-		return itemsLoadedMsg{items}
+		return itemsLoadedMsg{
+			items:         items,
+			id:            req.id,
+			nextPageToken: nextPageToken,
+			appendPage:    pageToken != "",
+		}
+	}
+}
+
+// reload abandons any in-flight listing or file load and loads the first
+// page of the current path, closing the browsed archive once the path
+// leads out of it
+func (m Model) reload() (Model, tea.Cmd) {
+	if m.archive != nil && !m.archive.contains(m.currentPath) {
+		m.archive = nil
+	}
+	m.listing = m.listing.next()
+	m.reloadStatus = ""
+	m.fileLoad = m.fileLoad.next()
+	m.loadingItems = true
+	m.loadingMore = false
+	m.nextPageToken = ""
+	return m, m.loadItems()
+}
+
+// reloadWithStatus reloads the current path and keeps showing status once
+// the listing has loaded
+func (m Model) reloadWithStatus(status string) (Model, tea.Cmd) {
+	m, cmd := m.reload()
+	m.statusMsg = status
+	m.reloadStatus = status
+	return m, cmd
+}
+
+// quit exits the program, asking first while transfers have not finished
+func (m Model) quit() (Model, tea.Cmd) {
+	if m.queue.Stats().Active() > 0 {
+		m.confirmQuit = true
+		m.statusMsg = "Confirm quit"
+		return m, nil
+	}
+	return m, tea.Quit
+}
+
+// renderQuitConfirm renders the dialog asking whether to quit and cancel
+// the unfinished transfers
+func (m Model) renderQuitConfirm() string {
+	body := fmt.Sprintf("%d transfers have not finished.\nQuitting cancels them.\n\n[y] quit   [n] keep running",
+		m.queue.Stats().Active())
+	return modalStyle.Render(modalTitleStyle.Render("Quit") + "\n\n" + body)
+}
+
+// Close stops the work still running in the background once the program
+// has exited: batch operations being planned, a running delete and the
+// transfer queue
+func (m Model) Close() {
+	m.planning.cancel()
+	m.deleteJob.cancel()
+	m.queue.Close()
+}
+
+// cancel abandons in-flight listings and file loads. Batch operations keep
+// being planned, since they were asked for by a key of their own.
+func (m Model) cancel() Model {
+	m.listing = m.listing.next()
+	m.fileLoad = m.fileLoad.next()
+	m.loadingItems = false
+	m.loadingMore = false
+	m.nextPageToken = ""
+	return m
+}
+
+// shouldLoadMore reports whether another page exists and the cursor is
+// within a screen of the end of the loaded items
+func (m Model) shouldLoadMore() bool {
+	if m.nextPageToken == "" || m.loadingMore || m.loadingItems {
+		return false
+	}
+	return len(m.list.Items())-m.list.Index() <= m.list.Paginator.PerPage
+}
+
+// loadedStatus describes how many items have been loaded so far
+func (m Model) loadedStatus() string {
+	switch {
+	case m.loadingMore:
+		return fmt.Sprintf("Loaded %d items, loading more...", len(m.list.Items()))
+	case m.nextPageToken != "":
+		return fmt.Sprintf("Loaded %d items, scroll down to load more", len(m.list.Items()))
+	default:
+		return fmt.Sprintf("Loaded %d items", len(m.list.Items()))
 	}
 }
 
@@ -274,37 +586,209 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		// If we're viewing a file, handle viewport keybindings
-		if m.viewingFile {
-			switch {
-			case key.Matches(msg, m.keyMap.Quit):
+		// If we're confirming to quit with unfinished transfers, only
+		// accept an answer
+		if m.confirmQuit {
+			switch msg.String() {
+			case "ctrl+c", "y", "Y", "q", "enter":
 				return m, tea.Quit
-			case key.Matches(msg, m.keyMap.Back):
-				m.viewingFile = false
+			case "n", "N", "esc":
+				m.confirmQuit = false
+				m.statusMsg = "Quit cancelled"
+			}
+			return m, nil
+		}
+
+		// If we're confirming a delete, only accept an answer
+		if m.confirmDelete != nil {
+			switch msg.String() {
+			case "ctrl+c":
+				return m.quit()
+			case "y", "Y", "enter":
+				plan := *m.confirmDelete
+				m.confirmDelete = nil
+				m.deleting = true
+				m.deletion = deleteProgressMsg{total: len(plan.objects)}
+				m.deleteJob = m.deleteJob.next()
+				m.statusMsg = fmt.Sprintf("Deleting %s...", plan.label)
+				return m, m.deleteObjects(plan)
+			case "n", "N", "esc":
+				m.confirmDelete = nil
+				m.statusMsg = "Delete cancelled"
+			}
+			return m, nil
+		}
+
+		// If a download would replace files, ask what to do with them
+		if m.confirmDownload != nil {
+			policy := ConflictPolicy("")
+			switch msg.String() {
+			case "ctrl+c":
+				return m.quit()
+			case "o", "O":
+				policy = ConflictOverwrite
+			case "s", "S":
+				policy = ConflictSkip
+			case "r", "R":
+				policy = ConflictRename
+			case "n", "N", "esc":
+				m.confirmDownload = nil
+				m.statusMsg = "Download cancelled"
+			}
+			if policy == "" {
 				return m, nil
-			default:
-				var cmd tea.Cmd
-				m.viewport, cmd = m.viewport.Update(msg)
-				return m, cmd
 			}
+
+			plan := *m.confirmDownload
+			m.confirmDownload = nil
+			return m.startDownload(plan, policy)
+		}
+
+		// If a copy, move or rename would replace objects, ask what to do
+		// with them
+		if m.confirmCopy != nil {
+			policy := ConflictPolicy("")
+			switch msg.String() {
+			case "ctrl+c":
+				return m.quit()
+			case "o", "O":
+				policy = ConflictOverwrite
+			case "s", "S":
+				policy = ConflictSkip
+			case "n", "N", "esc":
+				m.statusMsg = fmt.Sprintf("%s cancelled", m.confirmCopy.label)
+				m.confirmCopy = nil
+			}
+			if policy == "" {
+				return m, nil
+			}
+
+			plan := *m.confirmCopy
+			m.confirmCopy = nil
+			return m.queueCopies(plan, policy)
+		}
+
+		// If the transfer panel is open, handle its keybindings
+		if m.showTransfers {
+			return m.updateTransferPanel(msg)
+		}
+
+		// If we're choosing where to download to, handle the path prompt
+		if m.choosingDir {
+			switch msg.String() {
+			case "ctrl+c":
+				return m.quit()
+			case "esc":
+				m.choosingDir = false
+				m.statusMsg = "Download cancelled"
+				return m, nil
+			case "enter":
+				m.choosingDir = false
+				dir := config.ExpandHome(strings.TrimSpace(m.dirInput.Value()))
+				if dir == "" {
+					dir = "."
+				}
+				m.statusMsg = fmt.Sprintf("Listing objects to download to %s...", dir)
+				return m, m.planDownload(m.downloadItems, dir, false)
+			}
+
+			var cmd tea.Cmd
+			m.dirInput, cmd = m.dirInput.Update(msg)
+			return m, cmd
+		}
+
+		// If we're renaming, handle the name prompt
+		if m.renaming {
+			switch msg.String() {
+			case "ctrl+c":
+				return m.quit()
+			case "esc":
+				m.renaming = false
+				m.statusMsg = "Rename cancelled"
+				return m, nil
+			case "enter":
+				m.renaming = false
+				newName := strings.Trim(m.renameInput.Value(), "/")
+				if newName == "" || newName == m.renameItem.Name {
+					m.statusMsg = "Rename cancelled"
+					return m, nil
+				}
+				item := m.renameItem
+				m.statusMsg = fmt.Sprintf("Renaming %s to %s...", item.Name, newName)
+				return m, m.copyObjects("Rename", func(ctx context.Context) ([]copyPair, error) {
+					return planRename(ctx, m.backend, item, newName)
+				}, true)
+			}
+
+			var cmd tea.Cmd
+			m.renameInput, cmd = m.renameInput.Update(msg)
+			return m, cmd
+		}
+
+		// If we're picking a file to upload, handle file picker keybindings
+		if m.pickingUpload {
+			switch {
+			case msg.String() == "ctrl+c":
+				return m.quit()
+			case key.Matches(msg, m.keyMap.Cancel):
+				m.pickingUpload = false
+				m.statusMsg = "Upload cancelled"
+				return m, nil
+			}
+
+			var cmd tea.Cmd
+			m.picker, cmd = m.picker.Update(msg)
+			if ok, localPath := m.picker.DidSelectFile(msg); ok {
+				m.pickingUpload = false
+				m.statusMsg = fmt.Sprintf("Listing files to upload from %s...", localPath)
+				return m, m.uploadPath(localPath)
+			}
+			return m, cmd
+		}
+
+		// If we're viewing a file, handle viewer keybindings
+		if m.viewingFile {
+			return m.updateViewer(msg)
 		}
 
 		// Handle global keybindings
 		switch {
 		case key.Matches(msg, m.keyMap.Quit):
-			return m, tea.Quit
+			return m.quit()
 		case key.Matches(msg, m.keyMap.Help):
 			m.showHelp = !m.showHelp
 			return m, nil
+		case key.Matches(msg, m.keyMap.Transfers):
+			m.showTransfers = true
+			return m, nil
+		case key.Matches(msg, m.keyMap.Cancel):
+			m = m.cancel()
+			m.statusMsg = fmt.Sprintf("Cancelled, %d items loaded", len(m.list.Items()))
+			return m, nil
 		case key.Matches(msg, m.keyMap.Refresh):
 			m.statusMsg = "Refreshing..."
-			return m, m.loadItems()
+			if m.archive != nil {
+				m.archive = newArchive(m.archive.item)
+			}
+			return m.reload()
+		case key.Matches(msg, m.keyMap.StopDelete):
+			if !m.deleting {
+				m.statusMsg = "No delete is running"
+				return m, nil
+			}
+			m.deleteJob = m.deleteJob.next()
+			m.statusMsg = "Stopping delete..."
+			return m, nil
+		case m.archive != nil && key.Matches(msg, m.keyMap.Upload, m.keyMap.Delete, m.keyMap.Yank,
+			m.keyMap.Paste, m.keyMap.PasteMove, m.keyMap.Rename, m.keyMap.CopyURL):
+			m.statusMsg = "Archives are read-only, only viewing and downloading members is supported"
+			return m, nil
 		case key.Matches(msg, m.keyMap.Back):
 			if len(m.pathHistory) > 0 {
 				m.currentPath = m.pathHistory[len(m.pathHistory)-1]
 				m.pathHistory = m.pathHistory[:len(m.pathHistory)-1]
 				m.statusMsg = "Loading items..."
-				return m, m.loadItems()
+				return m.reload()
 			}
 			m.statusMsg = "Already at root level"
 			return m, nil
@@ -342,7 +826,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 					m.currentPath = selected.item.FullPath
 				}
-				return m, m.loadItems()
+				return m.reload()
+			}
+
+			// Archives open as virtual directories
+			if archiveFormat(selected.item.Name) != "" {
+				if m.archive != nil {
+					m.statusMsg = "Archives inside archives cannot be browsed"
+					return m, nil
+				}
+				m.statusMsg = fmt.Sprintf("Reading archive %s...", selected.item.Name)
+				m.pathHistory = append(m.pathHistory, m.currentPath)
+				m.currentPath = selected.item.FullPath
+				m.archive = newArchive(selected.item)
+				return m.reload()
 			}
 			return m, nil
 		case key.Matches(msg, m.keyMap.View):
@@ -364,32 +861,132 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			if !selected.item.IsDir {
 				m.statusMsg = fmt.Sprintf("Viewing %s", selected.item.Name)
+				m.fileLoad = m.fileLoad.next()
 				return m, m.loadFile(selected.item)
 			}
 			return m, nil
 		case key.Matches(msg, m.keyMap.Download):
-			if len(m.list.Items()) == 0 {
+			// Download the marked items, or the highlighted item, as one job
+			items := m.targets()
+			if len(items) == 0 {
 				return m, nil
 			}
 
-			// Check if the selected item is nil
-			selectedItem := m.list.SelectedItem()
-			if selectedItem == nil {
+			m.statusMsg = fmt.Sprintf("Listing objects to download to %s...", m.options.DownloadDir)
+			return m, m.planDownload(items, m.options.DownloadDir, false)
+		case key.Matches(msg, m.keyMap.Decompress):
+			// Download like d, decompressing compressed objects
+			items := m.targets()
+			if len(items) == 0 {
 				return m, nil
 			}
 
-			// Safely type assert with check
-			selected, ok := selectedItem.(ListItem)
-			if !ok {
+			m.statusMsg = fmt.Sprintf("Listing objects to download decompressed to %s...", m.options.DownloadDir)
+			return m, m.planDownload(items, m.options.DownloadDir, true)
+		case key.Matches(msg, m.keyMap.DownloadTo):
+			items := m.targets()
+			if len(items) == 0 {
 				return m, nil
 			}
 
-			if !selected.item.IsDir {
-				bucketName, objectName := gcs.ParsePath(selected.item.FullPath)
-				m.statusMsg = fmt.Sprintf("Downloading %s to current directory...", selected.item.Name)
-				return m, m.downloadFile(bucketName, objectName, selected.item.Name)
+			m.choosingDir = true
+			m.downloadItems = items
+			m.dirInput = textinput.New()
+			m.dirInput.Prompt = "Download to: "
+			m.dirInput.SetValue(m.options.DownloadDir)
+			m.dirInput.CursorEnd()
+			return m, m.dirInput.Focus()
+		case key.Matches(msg, m.keyMap.Upload):
+			if m.currentPath == "" {
+				m.statusMsg = "Open a bucket to upload into it"
+				return m, nil
+			}
+			m.picker = newUploadPicker(m.height - 8)
+			m.pickingUpload = true
+			return m, m.picker.Init()
+		case key.Matches(msg, m.keyMap.Delete):
+			items := m.targets()
+			if len(items) == 0 {
+				return m, nil
 			}
+			if m.currentPath == "" {
+				m.statusMsg = "Deleting buckets is not supported"
+				return m, nil
+			}
+			if m.deleting {
+				m.statusMsg = "A delete is already running, press X to stop it"
+				return m, nil
+			}
+
+			m.statusMsg = fmt.Sprintf("Counting objects in %d items...", len(items))
+			if len(items) == 1 {
+				m.statusMsg = fmt.Sprintf("Counting objects in %s...", items[0].Name)
+			}
+			return m, m.planDelete(items)
+		case key.Matches(msg, m.keyMap.Select):
+			return m.toggleMark(), nil
+		case key.Matches(msg, m.keyMap.SelectAll):
+			return m.markAll(), nil
+		case key.Matches(msg, m.keyMap.Invert):
+			return m.invertMarks(), nil
+		case key.Matches(msg, m.keyMap.Yank):
+			// Yank the marked items, or toggle the highlighted one
+			if marked := m.markedItems(); len(marked) > 0 {
+				m.clipboard = marked
+				m = m.setMarks(func(ListItem) bool { return false })
+				m.statusMsg = fmt.Sprintf("Yanked %d items, press p to paste them", len(m.clipboard))
+				return m, nil
+			}
+
+			selected, ok := m.selectedItem()
+			if !ok || !markable(selected) {
+				return m, nil
+			}
+			for i, item := range m.clipboard {
+				if item.FullPath == selected.item.FullPath {
+					m.clipboard = append(m.clipboard[:i:i], m.clipboard[i+1:]...)
+					m.statusMsg = fmt.Sprintf("Unyanked %s, %d items to paste", selected.item.Name, len(m.clipboard))
+					return m, nil
+				}
+			}
+			m.clipboard = append(m.clipboard, selected.item)
+			m.statusMsg = fmt.Sprintf("Yanked %s, %d items to paste", selected.item.Name, len(m.clipboard))
 			return m, nil
+		case key.Matches(msg, m.keyMap.Paste), key.Matches(msg, m.keyMap.PasteMove):
+			move := key.Matches(msg, m.keyMap.PasteMove)
+			switch {
+			case len(m.clipboard) == 0:
+				m.statusMsg = "Nothing to paste, press y to yank items for copy"
+				return m, nil
+			case m.currentPath == "":
+				m.statusMsg = "Open a bucket to paste into it"
+				return m, nil
+			}
+
+			items := m.clipboard
+			destPath := m.currentPath
+			m.clipboard = nil
+			label := "Paste"
+			if move {
+				label = "Move"
+			}
+			m.statusMsg = fmt.Sprintf("Listing objects to copy to %s...", destPath)
+			return m, m.copyObjects(label, func(ctx context.Context) ([]copyPair, error) {
+				return planPaste(ctx, m.backend, items, destPath)
+			}, move)
+		case key.Matches(msg, m.keyMap.Rename):
+			selected, ok := m.selectedItem()
+			if !ok || selected.item.Name == ".." || selected.item.IsBucket {
+				return m, nil
+			}
+
+			m.renaming = true
+			m.renameItem = selected.item
+			m.renameInput = textinput.New()
+			m.renameInput.Prompt = "Rename to: "
+			m.renameInput.SetValue(selected.item.Name)
+			m.renameInput.CursorEnd()
+			return m, m.renameInput.Focus()
 		case key.Matches(msg, m.keyMap.CopyURL):
 			if len(m.list.Items()) == 0 {
 				return m, nil
@@ -407,11 +1004,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 
+			// Copy the URLs of every marked object
+			if marked := m.markedItems(); len(marked) > 0 {
+				objects, _ := objectsOnly(marked)
+				if len(objects) == 0 {
+					m.statusMsg = "No objects marked"
+					return m, nil
+				}
+				m.statusMsg = fmt.Sprintf("Copied URLs for %d objects", len(objects))
+				m.showCopyMessage = true
+				m.copyMessageTimer = 10 // Show message for 10 updates
+				return m, m.copyURLs(objects)
+			}
+
 			if !selected.item.IsDir {
-				m.statusMsg = fmt.Sprintf("Copied gsutil URL for %s", selected.item.Name)
+				m.statusMsg = fmt.Sprintf("Copied URL for %s", selected.item.Name)
 				m.showCopyMessage = true
 				m.copyMessageTimer = 10 // Show message for 10 updates
-				return m, m.copyGsutilURL(selected.item.FullPath)
+				return m, m.copyURLs([]backend.Item{selected.item})
 			}
 			return m, nil
 		}
@@ -443,24 +1053,56 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case itemsLoadedMsg:
+		// Ignore pages of a listing we have navigated away from or reloaded
+		if msg.id != m.listing.id {
+			return m, nil
+		}
+
 		m.loadingItems = false
+		m.loadingMore = false
 		items := []list.Item{}
+		if msg.appendPage {
+			items = m.list.Items()
+		}
 		for _, item := range msg.items {
 			items = append(items, ListItem{item: item})
 		}
 		m.list.SetItems(items)
-		m.statusMsg = fmt.Sprintf("Loaded %d items", len(items))
+		m.nextPageToken = msg.nextPageToken
+
+		if m.shouldLoadMore() {
+			m.loadingMore = true
+			m.statusMsg = m.loadedStatus()
+			return m, m.loadMoreItems()
+		}
+		m.statusMsg = m.loadedStatus()
+		if m.reloadStatus != "" {
+			m.statusMsg = m.reloadStatus
+			m.reloadStatus = ""
+		}
 
 		return m, nil
 
 	case fileLoadedMsg:
-		m.fileContent = msg.content
+		if msg.id != m.fileLoad.id {
+			return m, nil
+		}
+		m.file = newFileView(msg)
 		m.viewingFile = true
-		m.viewport.SetContent(m.fileContent)
+		m.querying = false
+		m.searching = false
+		m.viewport.SetContent(m.file.render())
 		m.viewport.GotoTop()
+		m.statusMsg = m.viewerStatus()
 
 		return m, nil
 
+	case hexLoadedMsg:
+		return m.hexLoaded(msg)
+
+	case pagedLoadedMsg:
+		return m.pagedLoaded(msg)
+
 	case errMsg:
 		m.loadingItems = false
 		m.statusMsg = fmt.Sprintf("Error: %v", msg.err)
@@ -484,9 +1126,76 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case downloadDoneMsg:
-		m.statusMsg = fmt.Sprintf("Downloaded file to %s", msg.path)
+	case jobMsg:
+		// Handle the job's message and wait for the next one
+		var model tea.Model
+		var cmd tea.Cmd
+		model, cmd = m.Update(msg.msg)
+		return model, tea.Batch(cmd, waitForJob(msg.updates))
+
+	case uploadPlanMsg:
+		return m.queueUploads(msg)
+
+	case copyPlanMsg:
+		if msg.conflicts > 0 {
+			m.confirmCopy = &msg
+			m.statusMsg = fmt.Sprintf("Confirm %s", strings.ToLower(msg.label))
+			return m, nil
+		}
+		return m.queueCopies(msg, ConflictOverwrite)
+
+	case transferTickMsg:
+		return m.pollTransfers()
+
+	case deletePlanMsg:
+		if len(msg.plan.objects) == 0 {
+			m.statusMsg = fmt.Sprintf("Nothing to delete under %s", msg.plan.label)
+			return m, nil
+		}
+		m.confirmDelete = &msg.plan
+		m.statusMsg = "Confirm delete"
+		return m, nil
+
+	case deleteProgressMsg:
+		m.deletion = msg
+		return m, nil
+
+	case deleteDoneMsg:
+		m.deleting = false
+		switch {
+		case errors.Is(msg.err, context.Canceled):
+			m.statusMsg = fmt.Sprintf("Delete stopped, deleted %d of %d objects", msg.deleted, msg.total)
+		case msg.failed > 0:
+			m.statusMsg = fmt.Sprintf("Deleted %d objects, %d failed: %v", msg.deleted, msg.failed, msg.err)
+		default:
+			m.statusMsg = fmt.Sprintf("Deleted %d objects", msg.deleted)
+		}
+
+		// Drop the deleted objects from the listing
+		if msg.path == m.currentPath && !m.viewingFile {
+			return m.reloadWithStatus(m.statusMsg)
+		}
 		return m, nil
+
+	case downloadPlanMsg:
+		plan := msg.plan
+		switch {
+		case len(plan.targets) == 0:
+			m.statusMsg = "Nothing to download"
+			return m, nil
+		case plan.conflicts > 0 && m.options.Conflict == ConflictAsk:
+			m.confirmDownload = &plan
+			m.statusMsg = "Confirm download"
+			return m, nil
+		}
+		return m.startDownload(plan, m.options.Conflict)
+	}
+
+	// Let the file picker read directories
+	if m.pickingUpload {
+		var cmd tea.Cmd
+		m.picker, cmd = m.picker.Update(msg)
+		return m, cmd
 	}
 
 	// Handle list navigation
@@ -494,6 +1203,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m.list, cmd = m.list.Update(msg)
 	cmds = append(cmds, cmd)
 
+	// Fetch the next page as the cursor approaches the end of the list
+	if m.shouldLoadMore() {
+		m.loadingMore = true
+		m.statusMsg = m.loadedStatus()
+		cmds = append(cmds, m.loadMoreItems())
+	}
+
 	return m, tea.Batch(cmds...)
 }
 
@@ -510,13 +1226,44 @@ func (m Model) View() string {
 	if m.currentPath != "" {
 		pathInfo = m.currentPath
 	}
+	if marked := m.markedCount(); marked > 0 {
+		pathInfo += fmt.Sprintf("  [%d marked]", marked)
+	}
+	if len(m.clipboard) > 0 {
+		pathInfo += fmt.Sprintf("  [%d yanked]", len(m.clipboard))
+	}
 	title := titleStyle.Render("LazyBucket")
 	path := infoStyle.Copy().Width(m.width - lipgloss.Width(title) - 1).Render(pathInfo)
 	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, title, path))
 	s.WriteString("\n\n")
 
 	// Content
-	if m.viewingFile {
+	if m.confirmQuit {
+		s.WriteString(lipgloss.Place(m.width, m.height-4, lipgloss.Center, lipgloss.Center, m.renderQuitConfirm()))
+	} else if m.confirmDelete != nil {
+		s.WriteString(lipgloss.Place(m.width, m.height-4, lipgloss.Center, lipgloss.Center, m.renderDeleteConfirm()))
+	} else if m.confirmDownload != nil {
+		s.WriteString(lipgloss.Place(m.width, m.height-4, lipgloss.Center, lipgloss.Center, m.renderDownloadConfirm()))
+	} else if m.confirmCopy != nil {
+		s.WriteString(lipgloss.Place(m.width, m.height-4, lipgloss.Center, lipgloss.Center, m.renderCopyConfirm()))
+	} else if m.showTransfers {
+		s.WriteString(m.renderTransfers())
+	} else if m.pickingUpload {
+		s.WriteString(pickerHeaderStyle.Render(fmt.Sprintf("Upload to %s (enter: select file or folder, l/→: open folder, esc: cancel)", m.currentPath)))
+		s.WriteString("\n")
+		s.WriteString(m.picker.CurrentDirectory)
+		s.WriteString("\n\n")
+		s.WriteString(m.picker.View())
+	} else if m.viewingFile && m.file.hex != nil {
+		s.WriteString(m.renderViewer(m.file.hex.view(m.viewerSize())))
+	} else if m.viewingFile && m.file.paged != nil {
+		width, height := m.viewerSize()
+		s.WriteString(m.renderViewer(m.file.paged.view(width, height, m.file.lineNumbers)))
+	} else if m.viewingFile && m.file.structured && m.file.table != nil {
+		s.WriteString(m.renderViewer(m.file.table.view(m.viewerSize())))
+	} else if m.viewingFile && m.file.structured {
+		s.WriteString(m.renderViewer(m.file.tree.view(m.viewerSize())))
+	} else if m.viewingFile {
 		s.WriteString(m.viewport.View())
 	} else {
 		// Split view with list on left and details on right if width allows
@@ -536,8 +1283,28 @@ func (m Model) View() string {
 	if m.showCopyMessage {
 		statusMsg = copyMessageStyle("URL copied to clipboard!")
 	}
+	if m.renaming {
+		statusMsg = m.renameInput.View()
+	}
+	if m.choosingDir {
+		statusMsg = m.dirInput.View()
+	}
+	if m.querying || m.searching {
+		statusMsg = m.queryInput.View()
+	}
 	s.WriteString(statusMessageStyle(statusMsg))
 
+	// Transfer and delete progress
+	if stats := m.queue.Stats(); stats.Active() > 0 {
+		s.WriteString("\n")
+		s.WriteString(m.renderProgress(stats.Bytes, stats.Size, transferStatus(stats)))
+	}
+	if m.deleting {
+		s.WriteString("\n")
+		s.WriteString(m.renderProgress(int64(m.deletion.done), int64(m.deletion.total),
+			fmt.Sprintf("Deleting %d/%d objects, press X to stop", m.deletion.done, m.deletion.total)))
+	}
+
 	// Help
 	if m.showHelp {
 		s.WriteString("\n")
@@ -550,6 +1317,18 @@ func (m Model) View() string {
 	return s.String()
 }
 
+// renderProgress renders a progress bar followed by a status message
+func (m Model) renderProgress(done, total int64, status string) string {
+	ratio := 0.0
+	if total > 0 {
+		ratio = float64(done) / float64(total)
+	}
+
+	bar := m.progressBar
+	bar.Width = max(10, m.width/3)
+	return bar.ViewAs(ratio) + " " + statusMessageStyle(status)
+}
+
 // renderFileDetails renders the file details panel
 func (m Model) renderFileDetails() string {
 	if len(m.list.Items()) == 0 {
@@ -596,23 +1375,51 @@ func (m Model) renderFileDetails() string {
 	s.WriteString(detailsValueStyle.Render(selected.item.FullPath))
 	s.WriteString("\n\n")
 
-	// GsUtil URI
-	bucketName, objectName := gcs.ParsePath(selected.item.FullPath)
-	gsutilURI := fmt.Sprintf("gs://%s/%s", bucketName, objectName)
-	s.WriteString(detailsLabelStyle.Render("GsUtil URI: "))
-	s.WriteString(detailsValueStyle.Render(gsutilURI))
+	// Object URI, or that of the archive holding a member
+	if m.archive != nil {
+		bucketName, objectName := backend.ParsePath(m.archive.item.FullPath)
+		s.WriteString(detailsLabelStyle.Render("Archive: "))
+		s.WriteString(detailsValueStyle.Render(m.backend.URL(bucketName, objectName)))
+	} else {
+		bucketName, objectName := backend.ParsePath(selected.item.FullPath)
+		s.WriteString(detailsLabelStyle.Render("URI: "))
+		s.WriteString(detailsValueStyle.Render(m.backend.URL(bucketName, objectName)))
+	}
 	s.WriteString("\n\n")
 
 	// Actions
 	s.WriteString(detailsLabelStyle.Render("Actions:"))
 	s.WriteString("\n")
+	if m.archive == nil && archiveFormat(selected.item.Name) != "" {
+		s.WriteString(detailsValueStyle.Render("Press 'enter' to browse"))
+		s.WriteString("\n")
+	}
 	s.WriteString(detailsValueStyle.Render("Press 'd' to download"))
-	s.WriteString("\n")
-	s.WriteString(detailsValueStyle.Render("Press 'c' to copy gsutil URL"))
+	if m.archive == nil {
+		s.WriteString("\n")
+		s.WriteString(detailsValueStyle.Render("Press 'c' to copy URL"))
+	}
 
 	return detailsStyle.Render(s.String())
 }
 
+// ancestors returns the paths leading to fullPath, outermost first, so that
+// going back from a start path walks up through its parents
+func ancestors(fullPath string) []string {
+	history := []string{}
+	parts := strings.Split(strings.Trim(fullPath, "/"), "/")
+	for i := 1; i < len(parts); i++ {
+		history = append(history, strings.Join(parts[:i], "/"))
+	}
+	return history
+}
+
+// selectedItem returns the highlighted list item, if any
+func (m Model) selectedItem() (ListItem, bool) {
+	selected, ok := m.list.SelectedItem().(ListItem)
+	return selected, ok
+}
+
 // formatSize formats the file size in a human-readable format
 func formatSize(size int64) string {
 	const unit = 1024
@@ -627,53 +1434,168 @@ func formatSize(size int64) string {
 	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
 }
 
-// loadFile loads the content of a file
-func (m Model) loadFile(item gcs.Item) tea.Cmd {
+// loadFile loads the content of a file as part of the current file request
+func (m Model) loadFile(item backend.Item) tea.Cmd {
+	req := m.fileLoad
+	a := m.archive
 	return func() tea.Msg {
-		bucketName, objectName := gcs.ParsePath(item.FullPath)
-		content, err := m.gcsClient.GetObjectContent(bucketName, objectName)
-		if err != nil {
-			return errMsg{err}
+		bucketName, _ := backend.ParsePath(item.FullPath)
+
+		// Data files are previewed from their metadata and first rows
+		if format := dataFormat(item.Name); format != "" && a == nil {
+			r := backend.NewRangeReaderAt(req.ctx, m.backend, bucketName, item.Path, item.Size)
+			summary, table, err := previewData(format, r, tableRows)
+			if req.canceled() {
+				return nil
+			}
+			if err != nil {
+				return errMsg{err}
+			}
+			return fileLoadedMsg{name: item.Name, content: summary, table: table, id: req.id}
 		}
-		return fileLoadedMsg{content}
-	}
-}
 
-// downloadFile downloads a file from GCS to the local filesystem
-func (m Model) downloadFile(bucketName, objectName, fileName string) tea.Cmd {
-	return func() tea.Msg {
-		// Create a temporary file
-		f, err := os.Create(fileName)
+		msg := fileLoadedMsg{name: item.Name, id: req.id}
+		var reader io.ReadCloser
+		var attrs backend.Attrs
+		var err error
+		if a != nil {
+			reader, attrs, err = a.open(req.ctx, m.backend, item.Path)
+			msg.member = &memberSource{archive: a.item.Name, size: attrs.Size}
+		} else {
+			reader, attrs, err = m.backend.NewReader(req.ctx, bucketName, item.Path)
+		}
 		if err != nil {
+			if req.canceled() {
+				return nil
+			}
 			return errMsg{err}
 		}
-		defer f.Close()
+		defer reader.Close()
+		msg.contentType = attrs.ContentType
 
-		// Get the object content
-		content, err := m.gcsClient.GetObjectContent(bucketName, objectName)
+		// Compressed objects are decompressed on the fly, up to the size of
+		// the viewer window, and shown as the file they decompress to
+		decompressed, c, err := decompressReader(reader, item.Name, attrs.ContentEncoding)
 		if err != nil {
+			if req.canceled() {
+				return nil
+			}
 			return errMsg{err}
 		}
+		defer decompressed.Close()
+		var src io.Reader = decompressed
+		name := item.Name
+		streamed := a != nil
+		if c != nil {
+			msg.compression = &compression{codec: c, compressed: attrs.Size, uncompressed: -1}
+			name = decompressedName(item.Name)
+			streamed = true
+		} else if claimed := claimedCodec("", attrs.ContentEncoding); claimed != nil {
+			// Ranged reads of content the server decodes refer to the
+			// stored bytes, so it is read from the start like decompressed
+			// content rather than paged
+			msg.compression = &compression{codec: claimed, transcoded: true}
+			streamed = true
+		}
+		if streamed {
+			src = io.LimitReader(decompressed, m.options.ViewerWindow+1)
+		}
 
-		// Write the content to the file
-		_, err = f.WriteString(content)
-		if err != nil {
-			return errMsg{err}
+		// Tables only stream their first rows, keeping the raw text read
+		if delimiter := tableDelimiter(name, attrs.ContentType); delimiter != 0 {
+			var raw strings.Builder
+			table, err := readTable(io.TeeReader(src, &raw), delimiter, tableRows)
+			if req.canceled() {
+				return nil
+			}
+			msg.content = raw.String()
+			if err == nil {
+				msg.table = table
+				return msg
+			}
+			msg.tableErr = err
+		}
+
+		// Decompressed content and archive members can only be read from
+		// the start, so they are held in memory up to the viewer window and
+		// cut off there
+		if msg.table == nil && msg.tableErr == nil && streamed {
+			data, err := io.ReadAll(src)
+			if req.canceled() {
+				return nil
+			}
+			if err != nil {
+				return errMsg{fmt.Errorf("error reading %s: %v", item.Name, err)}
+			}
+			truncated := int64(len(data)) > m.options.ViewerWindow
+			if truncated {
+				data = data[:m.options.ViewerWindow]
+			}
+			binary := isBinary(data)
+			if i := bytes.LastIndexByte(data, '\n'); truncated && !binary && i >= 0 {
+				data = data[:i+1]
+			}
+			if msg.compression != nil {
+				msg.compression.truncated = truncated
+				msg.compression.uncompressed = int64(len(data))
+			} else if truncated {
+				msg.member.truncated = true
+				msg.member.shown = int64(len(data))
+			}
+			if binary {
+				msg.hex = newHexView(bucketName, item.Path, backend.Attrs{Size: int64(len(data))}, data)
+				return msg
+			}
+			msg.content = string(data)
+			return msg
+		}
+
+		// Binary objects open as a hex dump and text objects larger than
+		// the viewer window are paged. Both start from their first chunk
+		// and only fetch the rest when it is viewed.
+		if msg.table == nil && msg.tableErr == nil {
+			head, err := io.ReadAll(io.LimitReader(src, pagedChunk))
+			if req.canceled() {
+				return nil
+			}
+			if err != nil {
+				return errMsg{fmt.Errorf("error reading object: %v", err)}
+			}
+			if isBinary(head) {
+				msg.hex = newHexView(bucketName, item.Path, attrs, head[:min(len(head), hexWindow)])
+				return msg
+			}
+			if attrs.Size > m.options.ViewerWindow {
+				msg.paged = newPagedView(bucketName, item.Path, attrs, m.options.ViewerWindow, head)
+				return msg
+			}
+			msg.content = string(head)
 		}
 
-		return downloadDoneMsg{path: fileName}
+		content, err := io.ReadAll(src)
+		if req.canceled() {
+			return nil
+		}
+		if err != nil {
+			return errMsg{fmt.Errorf("error reading object: %v", err)}
+		}
+		msg.content += string(content)
+		return msg
 	}
 }
 
-// copyGsutilURL copies the gsutil URL to the clipboard
-func (m Model) copyGsutilURL(fullPath string) tea.Cmd {
+// copyURLs copies the object URLs to the clipboard, one per line
+func (m Model) copyURLs(items []backend.Item) tea.Cmd {
 	return func() tea.Msg {
-		bucketName, objectName := gcs.ParsePath(fullPath)
-		gsutilURI := fmt.Sprintf("gs://%s/%s", bucketName, objectName)
+		urls := make([]string, len(items))
+		for i, item := range items {
+			bucketName, objectName := backend.ParsePath(item.FullPath)
+			urls[i] = m.backend.URL(bucketName, objectName)
+		}
 
 		// Use the 'pbcopy' command on macOS to copy to clipboard
 		cmd := exec.Command("pbcopy")
-		cmd.Stdin = strings.NewReader(gsutilURI)
+		cmd.Stdin = strings.NewReader(strings.Join(urls, "\n"))
 		err := cmd.Run()
 		if err != nil {
 			return errMsg{err}
@@ -685,11 +1607,23 @@ func (m Model) copyGsutilURL(fullPath string) tea.Cmd {
 
 // Message types
 type itemsLoadedMsg struct {
-	items []gcs.Item
+	items         []backend.Item
+	id            int
+	nextPageToken string
+	appendPage    bool
 }
 
 type fileLoadedMsg struct {
-	content string
+	name        string
+	contentType string
+	content     string
+	table       *tableView
+	tableErr    error
+	hex         *hexView
+	paged       *pagedView
+	compression *compression
+	member      *memberSource
+	id          int
 }
 
 type errMsg struct {
@@ -699,7 +1633,3 @@ type errMsg struct {
 type copyDoneMsg struct{}
 
 type tickMsg struct{}
-
-type downloadDoneMsg struct {
-	path string
-}
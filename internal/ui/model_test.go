@@ -0,0 +1,446 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+	"github.com/fernandoabolafio/lazybucket/internal/transfer"
+)
+
+// testStore returns a store with two buckets, one holding a small tree
+func testStore() *memory.Backend {
+	b := memory.New()
+	b.CreateBucket("logs", time.Time{})
+	b.CreateBucket("photos", time.Time{})
+	for _, name := range []string{"2024/jan/a.jpg", "2024/jan/b.jpg", "2024/c.jpg", "readme.txt"} {
+		b.PutObject("photos", name, memory.Object{Data: []byte(name)})
+	}
+	return b
+}
+
+// newTestModel creates a model browsing b from startPath and runs its
+// first listing
+func newTestModel(t *testing.T, b backend.Backend, startPath string) Model {
+	t.Helper()
+	m := New(b, startPath, DefaultOptions())
+	m = update(m, tea.WindowSizeMsg{Width: 120, Height: 40})
+	return run(m, m.loadItems())
+}
+
+// update feeds msg to the model
+func update(m Model, msg tea.Msg) Model {
+	model, _ := m.Update(msg)
+	return model.(Model)
+}
+
+// run runs cmd and the commands that follow from it, feeding every
+// message to the model until no more commands are left
+func run(m Model, cmd tea.Cmd) Model {
+	for i := 0; cmd != nil && i < 100; i++ {
+		msg := cmd()
+		switch msg := msg.(type) {
+		case nil:
+			return m
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				m = run(m, cmd)
+			}
+			return m
+		}
+
+		var model tea.Model
+		model, cmd = m.Update(msg)
+		m = model.(Model)
+	}
+	return m
+}
+
+// keyMsg returns the key message of a key name
+func keyMsg(k string) tea.KeyMsg {
+	switch k {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEsc}
+	case "tab":
+		return tea.KeyMsg{Type: tea.KeyTab}
+	case "backspace":
+		return tea.KeyMsg{Type: tea.KeyBackspace}
+	case "down":
+		return tea.KeyMsg{Type: tea.KeyDown}
+	case "pgdown":
+		return tea.KeyMsg{Type: tea.KeyPgDown}
+	case "ctrl+c":
+		return tea.KeyMsg{Type: tea.KeyCtrlC}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
+
+// press presses a key and returns the command it starts without running it
+func press(m Model, k string) (Model, tea.Cmd) {
+	model, cmd := m.Update(keyMsg(k))
+	return model.(Model), cmd
+}
+
+// pressAndRun presses a key and runs the commands it starts
+func pressAndRun(m Model, k string) Model {
+	m, cmd := press(m, k)
+	return run(m, cmd)
+}
+
+// itemNames returns the names of the listed items
+func itemNames(m Model) []string {
+	var names []string
+	for _, item := range m.list.Items() {
+		names = append(names, item.(ListItem).item.Name)
+	}
+	return names
+}
+
+// selectItem highlights the listed item called name
+func selectItem(t *testing.T, m Model, name string) Model {
+	t.Helper()
+	for i, item := range m.list.Items() {
+		if item.(ListItem).item.Name == name {
+			m.list.Select(i)
+			return m
+		}
+	}
+	t.Fatalf("%s is not listed in %v", name, itemNames(m))
+	return m
+}
+
+func TestNavigation(t *testing.T) {
+	type step struct {
+		selectName string
+		key        string
+	}
+	tests := []struct {
+		name        string
+		startPath   string
+		steps       []step
+		wantPath    string
+		wantHistory []string
+		wantItems   []string
+	}{
+		{
+			name:        "open bucket",
+			steps:       []step{{"photos", "enter"}},
+			wantPath:    "photos",
+			wantHistory: []string{},
+			wantItems:   []string{"2024", "readme.txt"},
+		},
+		{
+			name:        "open directories",
+			steps:       []step{{"photos", "enter"}, {"2024", "enter"}, {"jan", "enter"}},
+			wantPath:    "photos/2024/jan",
+			wantHistory: []string{"photos", "photos/2024"},
+			wantItems:   []string{"..", "a.jpg", "b.jpg"},
+		},
+		{
+			name:        "up entry",
+			steps:       []step{{"photos", "enter"}, {"2024", "enter"}, {"jan", "enter"}, {"..", "enter"}},
+			wantPath:    "photos/2024",
+			wantHistory: []string{"photos"},
+			wantItems:   []string{"..", "c.jpg", "jan"},
+		},
+		{
+			name:        "back",
+			steps:       []step{{"photos", "enter"}, {"2024", "enter"}, {"", "b"}},
+			wantPath:    "photos",
+			wantHistory: []string{},
+			wantItems:   []string{"2024", "readme.txt"},
+		},
+		{
+			name:        "backspace",
+			steps:       []step{{"photos", "enter"}, {"2024", "enter"}, {"jan", "enter"}, {"", "backspace"}},
+			wantPath:    "photos/2024",
+			wantHistory: []string{"photos"},
+			wantItems:   []string{"..", "c.jpg", "jan"},
+		},
+		{
+			name:        "start path history",
+			startPath:   "photos/2024/jan/",
+			steps:       []step{{"", "b"}, {"", "b"}},
+			wantPath:    "photos",
+			wantHistory: []string{},
+			wantItems:   []string{"2024", "readme.txt"},
+		},
+		{
+			name:        "up entry from start path",
+			startPath:   "photos/2024",
+			steps:       []step{{"..", "enter"}},
+			wantPath:    "photos",
+			wantHistory: []string{},
+			wantItems:   []string{"2024", "readme.txt"},
+		},
+		{
+			name:        "files do not navigate",
+			steps:       []step{{"photos", "enter"}, {"readme.txt", "enter"}},
+			wantPath:    "photos",
+			wantHistory: []string{},
+			wantItems:   []string{"2024", "readme.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t, testStore(), tt.startPath)
+			for _, s := range tt.steps {
+				if s.selectName != "" {
+					m = selectItem(t, m, s.selectName)
+				}
+				m = pressAndRun(m, s.key)
+			}
+
+			if m.currentPath != tt.wantPath {
+				t.Errorf("currentPath = %q, want %q", m.currentPath, tt.wantPath)
+			}
+			if !reflect.DeepEqual(m.pathHistory, tt.wantHistory) {
+				t.Errorf("pathHistory = %q, want %q", m.pathHistory, tt.wantHistory)
+			}
+			if got := itemNames(m); !reflect.DeepEqual(got, tt.wantItems) {
+				t.Errorf("items = %q, want %q", got, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestStaleListingsAreDropped(t *testing.T) {
+	m := newTestModel(t, testStore(), "photos")
+	stale := m.listing.id
+
+	// Navigating supersedes the listing of the bucket
+	m = selectItem(t, m, "2024")
+	m, cmd := press(m, "enter")
+	if m.listing.id == stale {
+		t.Fatal("navigating kept the listing generation")
+	}
+
+	m = update(m, itemsLoadedMsg{items: []backend.Item{{Name: "stale"}}, id: stale})
+	if got := itemNames(m); reflect.DeepEqual(got, []string{"stale"}) {
+		t.Fatal("a page of the superseded listing was shown")
+	}
+	if !m.loadingItems {
+		t.Error("a page of the superseded listing ended loading")
+	}
+
+	m = run(m, cmd)
+	if got, want := itemNames(m), []string{"..", "c.jpg", "jan"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("items = %q, want %q", got, want)
+	}
+}
+
+func TestSupersededListingReturnsNothing(t *testing.T) {
+	m := newTestModel(t, testStore(), "photos")
+	m = selectItem(t, m, "2024")
+	m, first := press(m, "enter")
+	m, _ = press(m, "b")
+
+	// The listing of 2024 was cancelled by going back and yields no page
+	if msg := first(); msg != nil {
+		t.Errorf("superseded listing returned %T", msg)
+	}
+}
+
+// pagedStore returns a store with a bucket holding n objects named in
+// listing order
+func pagedStore(n int) *memory.Backend {
+	b := memory.New()
+	b.CreateBucket("big", time.Time{})
+	for i := 0; i < n; i++ {
+		b.PutObject("big", fmt.Sprintf("obj-%05d", i), memory.Object{Data: []byte("x")})
+	}
+	return b
+}
+
+func TestLoadMoreItems(t *testing.T) {
+	total := 2*backend.DefaultPageSize + backend.DefaultPageSize/2
+	m := newTestModel(t, pagedStore(total), "big")
+
+	if got := len(m.list.Items()); got != backend.DefaultPageSize {
+		t.Fatalf("first listing loaded %d items, want a page of %d", got, backend.DefaultPageSize)
+	}
+	if m.nextPageToken == "" {
+		t.Fatal("first page has no page token")
+	}
+	if m.shouldLoadMore() {
+		t.Error("should load more with the cursor at the top of the list")
+	}
+
+	for _, want := range []int{2 * backend.DefaultPageSize, total} {
+		// Scroll down to the last screen of loaded items
+		m.list.Select(len(m.list.Items()) - m.list.Paginator.PerPage - 1)
+		var cmd tea.Cmd
+		m, cmd = press(m, "down")
+		if !m.loadingMore {
+			t.Fatalf("scrolling to the end of %d items did not load more", len(m.list.Items()))
+		}
+		if m.shouldLoadMore() {
+			t.Error("should load more while a page is loading")
+		}
+
+		m = run(m, cmd)
+		if got := len(m.list.Items()); got != want {
+			t.Fatalf("loaded %d items, want %d", got, want)
+		}
+		if m.loadingMore {
+			t.Error("still loading more after the page loaded")
+		}
+	}
+
+	if m.nextPageToken != "" || m.shouldLoadMore() {
+		t.Errorf("more to load after the last page, token %q", m.nextPageToken)
+	}
+	for i, name := range itemNames(m) {
+		if want := fmt.Sprintf("obj-%05d", i); name != want {
+			t.Fatalf("item %d = %s, want %s", i, name, want)
+		}
+	}
+	if got, want := m.statusMsg, fmt.Sprintf("Loaded %d items", total); got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+}
+
+func TestLoadMoreItemsAfterRefresh(t *testing.T) {
+	m := newTestModel(t, pagedStore(backend.DefaultPageSize+10), "big")
+	m.list.Select(len(m.list.Items()) - 1)
+	m, more := press(m, "down")
+	if !m.loadingMore {
+		t.Fatal("scrolling to the end did not load more")
+	}
+
+	// The next page arrives after the listing was refreshed, which loads
+	// both pages again since the cursor stayed at the end
+	msg := more()
+	m = pressAndRun(m, "r")
+	m = update(m, msg)
+	if got, want := len(m.list.Items()), backend.DefaultPageSize+10; got != want {
+		t.Errorf("listed %d items, want %d", got, want)
+	}
+	if m.loadingMore {
+		t.Error("the page of the refreshed listing was taken as the next one")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name  string
+		start func(*testing.T, Model) (Model, tea.Cmd)
+		want  string
+	}{
+		{
+			name: "listing",
+			start: func(t *testing.T, m Model) (Model, tea.Cmd) {
+				return press(m, "r")
+			},
+		},
+		{
+			name: "file load",
+			start: func(t *testing.T, m Model) (Model, tea.Cmd) {
+				return press(selectItem(t, m, "readme.txt"), "v")
+			},
+		},
+		{
+			name: "download planning",
+			start: func(t *testing.T, m Model) (Model, tea.Cmd) {
+				return press(selectItem(t, m, "2024"), "d")
+			},
+			want: "ui.downloadPlanMsg",
+		},
+		{
+			name: "delete planning",
+			start: func(t *testing.T, m Model) (Model, tea.Cmd) {
+				return press(selectItem(t, m, "2024"), "x")
+			},
+			want: "ui.deletePlanMsg",
+		},
+		{
+			name: "paste planning",
+			start: func(t *testing.T, m Model) (Model, tea.Cmd) {
+				m, _ = press(selectItem(t, m, "2024"), "y")
+				return press(m, "p")
+			},
+			want: "ui.copyPlanMsg",
+		},
+		{
+			name: "upload planning",
+			start: func(t *testing.T, m Model) (Model, tea.Cmd) {
+				return m, m.uploadPath(t.TempDir())
+			},
+			want: "ui.uploadPlanMsg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t, testStore(), "photos")
+			m, cmd := tt.start(t, m)
+			m, _ = press(m, "esc")
+
+			got := ""
+			if msg := cmd(); msg != nil {
+				got = fmt.Sprintf("%T", msg)
+			}
+			if got != tt.want {
+				t.Errorf("after esc the command returned %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuit(t *testing.T) {
+	quits := func(cmd tea.Cmd) bool {
+		if cmd == nil {
+			return false
+		}
+		_, ok := cmd().(tea.QuitMsg)
+		return ok
+	}
+
+	m := newTestModel(t, testStore(), "photos")
+	if _, cmd := press(m, "q"); !quits(cmd) {
+		t.Fatal("q did not quit without transfers")
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	m.queue.Add(transfer.Download, "a.jpg", "a.jpg", 1, func(ctx context.Context, p *transfer.Progress) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return ctx.Err()
+	})
+
+	m, cmd := press(m, "q")
+	if quits(cmd) || !m.confirmQuit {
+		t.Fatal("q quit with an unfinished transfer")
+	}
+	if !strings.Contains(m.View(), "1 transfers have not finished") {
+		t.Error("the quit dialog is not shown")
+	}
+	m, cmd = press(m, "n")
+	if quits(cmd) || m.confirmQuit {
+		t.Fatal("n did not keep the program running")
+	}
+	if got, want := m.statusMsg, "Quit cancelled"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+
+	m, _ = press(m, "q")
+	if _, cmd = press(m, "y"); !quits(cmd) {
+		t.Fatal("y did not quit")
+	}
+	m.Close()
+	if got := m.queue.Stats().Cancelled; got != 1 {
+		t.Errorf("%d transfers cancelled on close, want 1", got)
+	}
+}
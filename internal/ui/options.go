@@ -0,0 +1,54 @@
+package ui
+
+import "fmt"
+
+// ConflictPolicy decides what a download does when the local file already
+// exists with different content
+type ConflictPolicy string
+
+const (
+	// ConflictOverwrite replaces the existing file
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictSkip keeps the existing file and skips the object
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictRename keeps the existing file and saves the object under
+	// a numbered name such as "report (1).csv"
+	ConflictRename ConflictPolicy = "rename"
+	// ConflictAsk asks which of the other policies to use
+	ConflictAsk ConflictPolicy = "ask"
+)
+
+// ParseConflictPolicy parses a conflict policy name
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictOverwrite, ConflictSkip, ConflictRename, ConflictAsk:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (expected overwrite, skip, rename or ask)", s)
+}
+
+// Options configures the UI
+type Options struct {
+	// DownloadDir is the directory downloads are written to
+	DownloadDir string
+
+	// Workers is the number of transfers run concurrently
+	Workers int
+
+	// Conflict is what to do when a download would replace a local file
+	Conflict ConflictPolicy
+
+	// ViewerWindow is the number of bytes of an object the viewer keeps in
+	// memory. Larger text objects are fetched in chunks as they scroll.
+	ViewerWindow int64
+}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		DownloadDir:  ".",
+		Workers:      4,
+		Conflict:     ConflictAsk,
+		ViewerWindow: 16 << 20,
+	}
+}
@@ -0,0 +1,594 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// pagedChunk is the size of the ranged reads of a paged view
+	pagedChunk = 1 << 20
+
+	// pagedLineMax is the longest line a paged view shows as one line.
+	// Longer lines, up to objects without any newline, are wrapped into
+	// pieces of this size so that a page never needs more than the window.
+	pagedLineMax = 16 << 10
+)
+
+// pagedView shows a text object too large to hold in memory. It keeps a
+// window of the object's bytes and fetches the chunks before or after it
+// with ranged reads as the user scrolls, dropping chunks at the other end
+// to stay within its memory limit.
+type pagedView struct {
+	bucketName string
+	objectName string
+	size       int64
+	generation string
+	window     int64
+
+	// data holds the bytes from start on and lines the complete lines in
+	// it, with offsets their positions in the object. numbers counts the
+	// lines before each one that start after a newline, so that the
+	// pieces of a wrapped line share its number. baseLine is the line
+	// number of the line holding data[0], or 0 when it is unknown because
+	// the window jumped past the start of the object.
+	data     []byte
+	start    int64
+	lines    [][]byte
+	offsets  []int64
+	numbers  []int64
+	baseLine int64
+	top      int
+
+	// pending is the start of the range being fetched, or -1, and dir
+	// whether it lies after (1) or before (-1) the window. jump is set
+	// while the start (-1) or end (1) of the object is being fetched.
+	pending int64
+	dir     int
+	jump    int
+	err     error
+
+	// filled is how much was fetched to fill the page since the last key,
+	// which stops at the size of the window
+	filled int64
+
+	// search is the last search, whose matches are found one at a time
+	search *pagedSearch
+}
+
+// pagedSearch is a search in a paged view. The object is not held in full,
+// so only the current match is known; the next one is looked for in the
+// lines held, and in the chunks after or before them if it is not there.
+type pagedSearch struct {
+	re         *regexp.Regexp
+	pattern    string
+	ignoreCase bool
+
+	// line and start are where the search continues from: the offset of
+	// a line and the byte in it a match must come after or before
+	line  int64
+	start int
+
+	// match is the offset of the line holding the current match, from
+	// byte matchStart to byte matchEnd, if found is set
+	match      int64
+	matchStart int
+	matchEnd   int
+	found      bool
+
+	// seeking is the direction the search is paging through the object
+	// in, or 0. exhausted is set when it reached the end of the object in
+	// that direction without a match.
+	seeking   int
+	exhausted bool
+}
+
+// findNext looks for the next match in direction dir in the lines held,
+// scrolling it into view. If there is none, the search continues from the
+// last line held in that direction.
+func (p *pagedView) findNext(dir, height int) bool {
+	s := p.search
+	show := func(i int, loc []int) bool {
+		s.match, s.matchStart, s.matchEnd, s.found = p.offsets[i], loc[0], loc[1], true
+		s.line, s.start = s.match, s.matchStart
+		p.top = i - height/3
+		p.move(0, height)
+		return true
+	}
+
+	if dir > 0 {
+		for i, line := range p.lines {
+			if p.offsets[i] < s.line {
+				continue
+			}
+			for _, loc := range s.re.FindAllIndex(line, -1) {
+				if loc[0] < loc[1] && (p.offsets[i] > s.line || loc[0] > s.start) {
+					return show(i, loc)
+				}
+			}
+		}
+		if n := len(p.lines); n > 0 {
+			s.line, s.start = p.offsets[n-1], len(p.lines[n-1])
+		}
+		return false
+	}
+
+	for i := len(p.lines) - 1; i >= 0; i-- {
+		if p.offsets[i] > s.line {
+			continue
+		}
+		locs := s.re.FindAllIndex(p.lines[i], -1)
+		for j := len(locs) - 1; j >= 0; j-- {
+			if loc := locs[j]; loc[0] < loc[1] && (p.offsets[i] < s.line || loc[0] < s.start) {
+				return show(i, loc)
+			}
+		}
+	}
+	if len(p.lines) > 0 {
+		s.line, s.start = p.offsets[0], 0
+	}
+	return false
+}
+
+// mark highlights the matches in text, the shown part of the line at
+// offset
+func (s *pagedSearch) mark(text string, offset int64) string {
+	var b strings.Builder
+	pos := 0
+	for _, loc := range s.re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		style := searchMatchStyle
+		if s.found && offset == s.match && loc[0] == s.matchStart {
+			style = searchCurrentStyle
+		}
+		b.WriteString(text[pos:loc[0]])
+		b.WriteString(style.Render(text[loc[0]:loc[1]]))
+		pos = loc[1]
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+// describe summarises the search for the status bar
+func (s *pagedSearch) describe() string {
+	label := searchLabel(s.pattern, s.ignoreCase)
+	switch {
+	case s.seeking != 0:
+		return fmt.Sprintf("[searching for %s…]", label)
+	case s.exhausted && s.found:
+		return fmt.Sprintf("[no more matches for %s]", label)
+	case s.exhausted:
+		return fmt.Sprintf("[no matches for %s]", label)
+	}
+	return fmt.Sprintf("[match for %s]", label)
+}
+
+// newPagedView creates a paged view of an object whose first bytes are
+// head. The window is never smaller than two chunks.
+func newPagedView(bucketName, objectName string, attrs backend.Attrs, window int64, head []byte) *pagedView {
+	p := &pagedView{
+		bucketName: bucketName,
+		objectName: objectName,
+		size:       attrs.Size,
+		generation: attrs.Generation,
+		window:     max(window, 2*pagedChunk),
+		data:       head,
+		baseLine:   1,
+		pending:    -1,
+	}
+	p.split()
+	return p
+}
+
+// end returns the offset just past the bytes held
+func (p *pagedView) end() int64 {
+	return p.start + int64(len(p.data))
+}
+
+// split splits the data into lines. Lines cut off at either end of the
+// data are left out unless they are at the ends of the object, or the
+// pieces of a line longer than pagedLineMax are complete.
+func (p *pagedView) split() {
+	begin, end := 0, len(p.data)
+	if i := bytes.IndexByte(p.data, '\n'); p.start > 0 && i >= 0 && i < min(end-1, pagedLineMax) {
+		begin = i + 1
+	}
+	if p.end() < p.size {
+		last := max(begin, bytes.LastIndexByte(p.data, '\n')+1)
+		end = last + (end-last)/pagedLineMax*pagedLineMax
+	}
+
+	p.lines = p.lines[:0]
+	p.offsets = p.offsets[:0]
+	p.numbers = p.numbers[:0]
+	var number int64
+	for begin < end {
+		line := p.data[begin:end]
+		cut := len(line)
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			cut = i
+		}
+		cut = min(cut, pagedLineMax)
+		p.lines = append(p.lines, bytes.TrimSuffix(line[:cut], []byte("\r")))
+		p.offsets = append(p.offsets, p.start+int64(begin))
+		p.numbers = append(p.numbers, number)
+		begin += cut
+		if cut < len(line) && line[cut] == '\n' {
+			begin++
+			number++
+		}
+	}
+}
+
+// firstLine returns the line number of lines[0], or 0 if it is unknown
+func (p *pagedView) firstLine() int64 {
+	if p.baseLine == 0 || len(p.offsets) == 0 {
+		return 0
+	}
+	return p.baseLine + int64(bytes.Count(p.data[:p.offsets[0]-p.start], []byte("\n")))
+}
+
+// move scrolls by delta lines
+func (p *pagedView) move(delta, height int) {
+	p.top = max(0, min(p.top+delta, len(p.lines)-height))
+}
+
+// goToStart scrolls to the first line, fetching it if needed
+func (p *pagedView) goToStart() {
+	if p.start == 0 {
+		p.top = 0
+		return
+	}
+	p.jump = -1
+}
+
+// goToEnd scrolls to the last line, fetching it if needed
+func (p *pagedView) goToEnd(height int) {
+	if p.end() == p.size {
+		p.move(len(p.lines), height)
+		return
+	}
+	p.jump = 1
+}
+
+// missing returns the range to fetch next: the start or end of the object
+// after a jump, otherwise the chunk after or before the window once the
+// view scrolls within a page of its end. A positive dir only looks ahead
+// and a negative one only behind, so that follow-up fetches never undo
+// each other. It also returns the direction of the range it picked.
+func (p *pagedView) missing(height, dir int) (int64, int64, int, bool) {
+	var start, length int64
+	var next int
+	switch {
+	case p.jump < 0:
+		start, length, next = 0, min(pagedChunk, p.size), 1
+	case p.jump > 0:
+		start = max(0, p.size-pagedChunk)
+		length, next = p.size-start, -1
+	case dir >= 0 && p.end() < p.size && p.top+2*height >= len(p.lines):
+		start, length, next = p.end(), min(pagedChunk, p.size-p.end()), 1
+	case dir <= 0 && p.start > 0 && p.top < height:
+		start = max(0, p.start-pagedChunk)
+		length, next = p.start-start, -1
+	default:
+		return 0, 0, 0, false
+	}
+	if start == p.pending {
+		return 0, 0, 0, false
+	}
+	return start, length, next, true
+}
+
+// add adds a fetched range to the window, keeping the top line in place
+// unless the range was fetched for a jump
+func (p *pagedView) add(start int64, data []byte, height int) {
+	var topOffset int64 = -1
+	if p.top < len(p.offsets) {
+		topOffset = p.offsets[p.top]
+	}
+
+	switch {
+	case p.jump != 0 || (start != p.end() && start+int64(len(data)) != p.start):
+		p.data = data
+		p.start = start
+		p.baseLine = 0
+	case start == p.end():
+		p.data = append(p.data, data...)
+		if over := int64(len(p.data)) - p.window; over > 0 {
+			if p.baseLine > 0 {
+				p.baseLine += int64(bytes.Count(p.data[:over], []byte("\n")))
+			}
+			p.data = append([]byte(nil), p.data[over:]...)
+			p.start += over
+		}
+	default:
+		if p.baseLine > 0 {
+			p.baseLine -= int64(bytes.Count(data, []byte("\n")))
+		}
+		joined := make([]byte, 0, min(p.window, int64(len(data)+len(p.data))))
+		joined = append(joined, data...)
+		p.data = append(joined, p.data[:cap(joined)-len(data)]...)
+		p.start = start
+	}
+	if p.start == 0 {
+		p.baseLine = 1
+	}
+	p.split()
+
+	switch {
+	case p.jump < 0:
+		p.top = 0
+	case p.jump > 0:
+		p.top = len(p.lines)
+	case topOffset >= 0:
+		p.top = sort.Search(len(p.offsets), func(i int) bool { return p.offsets[i] >= topOffset })
+	}
+	p.jump = 0
+	p.move(0, height)
+}
+
+// view renders the visible lines, numbered if lineNumbers is set and the
+// line numbers are known
+func (p *pagedView) view(width, height int, lineNumbers bool) string {
+	first := p.firstLine()
+	gutter := 0
+	if lineNumbers && first > 0 && len(p.numbers) > 0 {
+		gutter = len(fmt.Sprint(first + p.numbers[len(p.numbers)-1]))
+	}
+
+	var s strings.Builder
+	for i := p.top; i < len(p.lines) && i < p.top+height; i++ {
+		if i > p.top {
+			s.WriteString("\n")
+		}
+		switch {
+		case gutter > 0 && i > 0 && p.numbers[i] == p.numbers[i-1]:
+			// Further pieces of a wrapped line
+			s.WriteString(lineNumberStyle.Render(fmt.Sprintf("%*s │ ", gutter, "↪")))
+		case gutter > 0:
+			s.WriteString(lineNumberStyle.Render(fmt.Sprintf("%*d │ ", gutter, first+p.numbers[i])))
+		}
+		// Very long lines are cut before measuring them
+		line := p.lines[i][:min(len(p.lines[i]), 4*width)]
+		text := runewidth.Truncate(string(line), width, "")
+		if p.search != nil {
+			text = p.search.mark(text, p.offsets[i])
+		}
+		s.WriteString(text)
+	}
+	if len(p.lines) == 0 {
+		s.WriteString(jsonDimStyle.Render("loading…"))
+	}
+	return s.String()
+}
+
+// pagedStatus describes the paged view for the status bar
+func (m Model) pagedStatus() string {
+	p := m.file.paged
+	status := fmt.Sprintf("Viewing %s (%s, read in chunks", m.file.name, formatSize(p.size))
+	if first := p.firstLine(); first > 0 && p.top < len(p.numbers) {
+		status += fmt.Sprintf(", line %d", first+p.numbers[p.top])
+	}
+	if p.top < len(p.offsets) && p.size > 0 {
+		status += fmt.Sprintf(", %d%%", p.offsets[p.top]*100/p.size)
+	}
+	status += fmt.Sprintf(", %s in memory", formatSize(int64(len(p.data))))
+	switch {
+	case p.err != nil:
+		status += fmt.Sprintf(", %v", p.err)
+	case p.pending >= 0:
+		status += ", loading"
+	}
+	return status + ")"
+}
+
+// updatePagedView handles keys while a large text object is shown
+func (m Model) updatePagedView(msg tea.KeyMsg) (Model, tea.Cmd) {
+	p := m.file.paged
+	_, height := m.viewerSize()
+
+	switch {
+	case key.Matches(msg, m.keyMap.Quit):
+		return m.quit()
+	case key.Matches(msg, m.keyMap.Back):
+		m.viewingFile = false
+		return m, nil
+	case key.Matches(msg, m.keyMap.LineNumbers):
+		m.file.lineNumbers = !m.file.lineNumbers
+	case key.Matches(msg, m.keyMap.Highlight):
+		m.statusMsg = "No syntax highlighting for objects read in chunks"
+		return m, nil
+	case key.Matches(msg, m.keyMap.Search):
+		return m.startSearch()
+	case key.Matches(msg, m.keyMap.NextMatch), key.Matches(msg, m.keyMap.PrevMatch):
+		if p.search == nil {
+			m.statusMsg = "Nothing searched yet, press / to search"
+			return m, nil
+		}
+		delta := 1
+		if key.Matches(msg, m.keyMap.PrevMatch) {
+			delta = -1
+		}
+		return m.findPaged(delta)
+	case key.Matches(msg, m.keyMap.Cancel) && p.search != nil:
+		// Clear the highlighted matches, which also stops a search that
+		// is still paging through the object
+		p.search = nil
+	default:
+		switch msg.String() {
+		case "up", "k":
+			p.move(-1, height)
+		case "down", "j":
+			p.move(1, height)
+		case "pgup", "ctrl+u":
+			p.move(-height, height)
+		case "pgdown", "ctrl+d":
+			p.move(height, height)
+		case "home", "g":
+			p.goToStart()
+		case "end", "G":
+			p.goToEnd(height)
+		default:
+			return m, nil
+		}
+	}
+
+	p.filled = 0
+	cmd := m.fetchPaged(0)
+	m.statusMsg = m.viewerStatus()
+	return m, cmd
+}
+
+// searchPaged searches the paged view for pattern from its top line on. An
+// empty pattern clears the search.
+func (m Model) searchPaged(pattern string) (Model, tea.Cmd) {
+	p := m.file.paged
+	if pattern == "" {
+		p.search = nil
+		m.statusMsg = m.viewerStatus()
+		return m, nil
+	}
+	re, err := compilePattern(pattern, m.ignoreCase)
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("Invalid pattern: %v", err)
+		return m, nil
+	}
+
+	p.search = &pagedSearch{re: re, pattern: pattern, ignoreCase: m.ignoreCase, line: p.start, start: -1}
+	if p.top < len(p.offsets) {
+		p.search.line = p.offsets[p.top]
+	}
+	return m.findPaged(1)
+}
+
+// findPaged moves the search of the paged view to the next match in
+// direction dir. If the lines held have none, it scrolls to their end and
+// fetches the chunk beyond, and pagedLoaded carries on from there until a
+// match turns up or the object ends.
+func (m Model) findPaged(dir int) (Model, tea.Cmd) {
+	p := m.file.paged
+	s := p.search
+	_, height := m.viewerSize()
+	s.seeking, s.exhausted = 0, false
+
+	var cmd tea.Cmd
+	switch {
+	case p.findNext(dir, height):
+		cmd = m.fetchPaged(0)
+	case dir > 0 && p.end() < p.size:
+		s.seeking = dir
+		p.top = max(0, len(p.lines)-height)
+		cmd = m.fetchPaged(dir)
+	case dir < 0 && p.start > 0:
+		s.seeking = dir
+		p.top = 0
+		cmd = m.fetchPaged(dir)
+	default:
+		s.exhausted = true
+		if s.found {
+			s.line, s.start = s.match, s.matchStart
+		}
+	}
+	m.statusMsg = m.viewerStatus()
+	return m, cmd
+}
+
+// fetchPaged fetches the next range the paged view needs in direction dir,
+// or in either direction if dir is 0
+func (m Model) fetchPaged(dir int) tea.Cmd {
+	p := m.file.paged
+	_, height := m.viewerSize()
+	start, length, next, ok := p.missing(height, dir)
+	if !ok {
+		return nil
+	}
+	p.pending = start
+	p.dir = next
+	p.err = nil
+
+	req := m.fileLoad
+	return func() tea.Msg {
+		data, generation, err := fetchRange(req.ctx, m.backend, p.bucketName, p.objectName, start, length)
+		return pagedLoadedMsg{id: req.id, start: start, data: data, generation: generation, err: err}
+	}
+}
+
+// pagedLoaded adds a fetched range to the paged view unless the object
+// changed since it was opened
+func (m Model) pagedLoaded(msg pagedLoadedMsg) (Model, tea.Cmd) {
+	p := m.file.paged
+	if msg.id != m.fileLoad.id || p == nil || msg.start != p.pending {
+		return m, nil
+	}
+	p.pending = -1
+
+	var cmd tea.Cmd
+	switch {
+	case m.fileLoad.canceled():
+		return m, nil
+	case msg.err != nil:
+		p.err = msg.err
+		p.jump = 0
+	case msg.generation != p.generation:
+		p.err = fmt.Errorf("%s changed since it was opened, reopen it to see the new version", p.objectName)
+		p.jump = 0
+	case p.search != nil && p.search.seeking != 0:
+		_, height := m.viewerSize()
+		p.add(msg.start, msg.data, height)
+		// Keep looking for the next match in the chunk fetched for it
+		m, cmd = m.findPaged(p.search.seeking)
+	default:
+		_, height := m.viewerSize()
+		p.add(msg.start, msg.data, height)
+		// Keep going the same way until a page is filled, but never
+		// fetch more than the window holds for it
+		p.filled += int64(len(msg.data))
+		if p.filled < p.window {
+			cmd = m.fetchPaged(p.dir)
+		}
+	}
+	if p.err != nil && p.search != nil {
+		p.search.seeking = 0
+	}
+
+	if m.viewingFile && !m.querying {
+		m.statusMsg = m.viewerStatus()
+	}
+	return m, cmd
+}
+
+// fetchRange reads length bytes of an object from offset on, returning
+// them with the generation of the object they were read from
+func fetchRange(ctx context.Context, b backend.Backend, bucketName, objectName string, offset, length int64) ([]byte, string, error) {
+	reader, attrs, err := b.NewRangeReader(ctx, bucketName, objectName, offset, length)
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading object: %v", err)
+	}
+	return data, attrs.Generation, nil
+}
+
+type pagedLoadedMsg struct {
+	id         int
+	start      int64
+	data       []byte
+	generation string
+	err        error
+}
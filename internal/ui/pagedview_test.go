@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+)
+
+// newWindowModel creates a model browsing photos in b whose viewer holds
+// at most window bytes of an object
+func newWindowModel(t *testing.T, b backend.Backend, window int64) Model {
+	t.Helper()
+	opts := DefaultOptions()
+	opts.ViewerWindow = window
+	m := New(b, "photos", opts)
+	m = update(m, tea.WindowSizeMsg{Width: 120, Height: 40})
+	return run(m, m.loadItems())
+}
+
+// countedRanges is a store that counts the bytes of its ranged reads
+type countedRanges struct {
+	*memory.Backend
+	read atomic.Int64
+}
+
+func (b *countedRanges) NewRangeReader(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, backend.Attrs, error) {
+	reader, attrs, err := b.Backend.NewRangeReader(ctx, bucketName, objectName, offset, length)
+	if err == nil {
+		b.read.Add(min(length, attrs.Size-offset))
+	}
+	return reader, attrs, err
+}
+
+func TestPagedSplit(t *testing.T) {
+	long := strings.Repeat("x", pagedLineMax+10)
+	tests := []struct {
+		name    string
+		start   int64
+		size    int64
+		data    string
+		want    []string
+		numbers []int64
+	}{
+		{
+			name:    "whole object",
+			size:    8,
+			data:    "a\nb\r\nc\n",
+			want:    []string{"a", "b", "c"},
+			numbers: []int64{0, 1, 2},
+		},
+		{
+			name:    "line cut off at the end",
+			size:    100,
+			data:    "a\nb\npart",
+			want:    []string{"a", "b"},
+			numbers: []int64{0, 1},
+		},
+		{
+			name:    "line cut off at the start",
+			start:   10,
+			size:    100,
+			data:    "tail\na\nb\n",
+			want:    []string{"a", "b"},
+			numbers: []int64{0, 1},
+		},
+		{
+			name:    "overlong line at the end of the object",
+			size:    int64(len(long) + 2),
+			data:    "a\n" + long,
+			want:    []string{"a", long[:pagedLineMax], long[pagedLineMax:]},
+			numbers: []int64{0, 1, 1},
+		},
+		{
+			name:    "only the complete pieces of an overlong line cut off",
+			size:    1 << 30,
+			data:    "a\n" + long,
+			want:    []string{"a", long[:pagedLineMax]},
+			numbers: []int64{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPagedView("bucket", "object", backend.Attrs{Size: tt.size}, 0, []byte(tt.data))
+			p.start = tt.start
+			p.split()
+			var got []string
+			for _, line := range p.lines {
+				got = append(got, string(line))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %.40q, want %.40q", got, tt.want)
+			}
+			if !reflect.DeepEqual(p.numbers, tt.numbers) {
+				t.Errorf("numbers = %v, want %v", p.numbers, tt.numbers)
+			}
+		})
+	}
+}
+
+func TestPagedScroll(t *testing.T) {
+	b := testStore()
+	m := newWindowModel(t, b, 1<<20)
+	m = viewObject(t, m, b, "big.log", pagedText(6<<20))
+	p := m.file.paged
+	width, height := m.viewerSize()
+
+	if !strings.HasPrefix(strings.TrimSpace(p.view(width, height, true)), "1 │ line 0000001") {
+		t.Errorf("the first line is not shown numbered:\n%.200s", p.view(width, height, true))
+	}
+
+	m = pressAndRun(m, "G")
+	if p.end() != p.size {
+		t.Fatal("G did not fetch the end of the object")
+	}
+	if view := p.view(width, height, false); !strings.HasSuffix(view, fmt.Sprintf("line %07d", (6<<20)/13+1)) {
+		t.Errorf("G does not show the last line:\n%.200s", view[len(view)-200:])
+	}
+	if p.firstLine() != 0 {
+		t.Error("line numbers are known after jumping to the end")
+	}
+	if int64(len(p.data)) > p.window {
+		t.Errorf("%d bytes held, more than the window of %d", len(p.data), p.window)
+	}
+
+	m = pressAndRun(m, "g")
+	if p.start != 0 || p.top != 0 || p.firstLine() != 1 {
+		t.Errorf("g did not return to the first line: start %d, top %d", p.start, p.top)
+	}
+}
+
+func TestPagedWithoutNewlines(t *testing.T) {
+	b := &countedRanges{Backend: testStore()}
+	m := newWindowModel(t, b, 1<<20)
+	m = viewObject(t, m, b.Backend, "blob.txt", strings.Repeat("x", 64<<20))
+	p := m.file.paged
+	if p == nil {
+		t.Fatal("blob.txt is not paged")
+	}
+	if len(p.lines) == 0 {
+		t.Fatal("nothing is shown of an object without newlines")
+	}
+
+	m = pressAndRun(m, "pgdown")
+	if got := b.read.Load(); got > p.window {
+		t.Errorf("scrolling a page read %d bytes, more than the window of %d", got, p.window)
+	}
+	for _, line := range p.lines {
+		if len(line) > pagedLineMax {
+			t.Fatalf("a line of %d bytes is shown unwrapped", len(line))
+		}
+	}
+}
+
+func TestViewLargeServerDecodedObject(t *testing.T) {
+	b := testStore()
+	m := newWindowModel(t, b, 1<<20)
+	data := pagedText(3 << 20)
+	b.PutObject("photos", "big.log.gz", memory.Object{Data: []byte(data), ContentEncoding: "gzip"})
+	m = pressAndRun(m, "r")
+	m = pressAndRun(selectItem(t, m, "big.log.gz"), "v")
+	if m.file.paged != nil {
+		t.Fatal("an object the server decodes is paged with ranged reads")
+	}
+	if c := m.file.compression; c == nil || !c.transcoded || !c.truncated {
+		t.Fatalf("compression = %+v, want it decoded by the server and cut off", c)
+	}
+	if !strings.Contains(m.statusMsg, "decompressed by the server, showing the first") {
+		t.Errorf("status = %q does not say the object was cut off", m.statusMsg)
+	}
+	if !strings.HasPrefix(data, m.file.content) || int64(len(m.file.content)) > 1<<20 {
+		t.Errorf("content holds %d bytes, want the start of the object up to the window", len(m.file.content))
+	}
+}
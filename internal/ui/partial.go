@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// checkpointInterval is how many bytes a download writes between saves of
+// its partial state
+const checkpointInterval = 8 << 20
+
+// activeParts holds the partial files that are being written, so that two
+// transfers never write the same one
+var activeParts sync.Map
+
+// partialState is kept next to a partial download so that it can be
+// resumed after the app quits or the connection drops
+type partialState struct {
+	Bucket     string `json:"bucket"`
+	Object     string `json:"object"`
+	Generation string `json:"generation"`
+	Size       int64  `json:"size"`
+
+	// Offset is the number of bytes of the partial file known to be on disk
+	Offset int64 `json:"offset"`
+}
+
+// partialPaths returns the paths of the partial content of a download to
+// fileName and of its state
+func partialPaths(fileName string) (string, string) {
+	dir, base := filepath.Split(fileName)
+	partPath := filepath.Join(dir, "."+base+".part")
+	return partPath, partPath + ".json"
+}
+
+// loadPartial loads the state of a partial download of bucketName/objectName.
+// It reports false unless the partial file holds at least the recorded
+// offset and the download can be resumed.
+func loadPartial(partPath, statePath, bucketName, objectName string) (partialState, bool) {
+	var state partialState
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return state, false
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, false
+	}
+	if state.Bucket != bucketName || state.Object != objectName || state.Generation == "" {
+		return state, false
+	}
+	if state.Offset <= 0 || state.Offset >= state.Size {
+		return state, false
+	}
+
+	info, err := os.Stat(partPath)
+	if err != nil || info.Size() < state.Offset {
+		return state, false
+	}
+	return state, true
+}
+
+// savePartial atomically replaces the state of a partial download
+func savePartial(statePath string, state partialState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error saving download state: %v", err)
+	}
+	if err := os.Rename(tmp, statePath); err != nil {
+		return fmt.Errorf("error saving download state: %v", err)
+	}
+	return nil
+}
+
+// removePartial removes the partial content and state of a download
+func removePartial(partPath, statePath string) {
+	os.Remove(partPath)
+	os.Remove(statePath)
+}
+
+// checkpointWriter writes a partial download and, when it is resumable,
+// regularly saves how much of it has reached the disk
+type checkpointWriter struct {
+	f         *os.File
+	statePath string
+	state     partialState
+	unsaved   int64
+}
+
+func (w *checkpointWriter) Write(b []byte) (int, error) {
+	n, err := w.f.Write(b)
+	w.state.Offset += int64(n)
+	w.unsaved += int64(n)
+	if err == nil && w.unsaved >= checkpointInterval {
+		err = w.checkpoint()
+	}
+	return n, err
+}
+
+// checkpoint flushes the partial file and saves its state. Downloads of
+// objects without a generation cannot be resumed, so nothing is saved.
+func (w *checkpointWriter) checkpoint() error {
+	w.unsaved = 0
+	if w.statePath == "" {
+		return nil
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	return savePartial(w.statePath, w.state)
+}
@@ -0,0 +1,438 @@
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+	"github.com/parquet-go/parquet-go"
+)
+
+// avroScanSize is how far into an Avro file blocks are counted. Avro keeps
+// no row count in its header, so larger files report a lower bound.
+const avroScanSize = 16 << 20
+
+// dataFormat returns the name of a columnar or row-based data format that
+// is previewed rather than read in full, or "" for other objects
+func dataFormat(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".parquet", ".pq":
+		return "Parquet"
+	case ".avro":
+		return "Avro"
+	}
+	return ""
+}
+
+// previewData reads the schema and the first maxRows rows of a Parquet or
+// Avro object. Only the parts of the object that are needed are fetched.
+// It returns a summary of the file and its rows as a table.
+func previewData(format string, r *backend.RangeReaderAt, maxRows int) (string, *tableView, error) {
+	var summary string
+	var table *tableView
+	var err error
+	switch format {
+	case "Parquet":
+		summary, table, err = previewParquet(r, maxRows)
+	case "Avro":
+		summary, table, err = previewAvro(r, maxRows)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading %s file: %v", format, err)
+	}
+
+	fetched, requests := r.Fetched()
+	unit := "ranged reads"
+	if requests == 1 {
+		unit = "ranged read"
+	}
+	summary += fmt.Sprintf("\nRead %s of %s in %d %s\n", formatSize(fetched), formatSize(r.Size()), requests, unit)
+	return summary, table, nil
+}
+
+// previewParquet reads the footer of a Parquet file and its first rows
+func previewParquet(r *backend.RangeReaderAt, maxRows int) (string, *tableView, error) {
+	file, err := parquet.OpenFile(r, r.Size(), parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return "", nil, err
+	}
+	meta := file.Metadata()
+
+	var codecs []string
+	seen := make(map[string]bool)
+	var s strings.Builder
+	s.WriteString("Row groups\n")
+	for i, rg := range meta.RowGroups {
+		var compressed int64
+		for _, column := range rg.Columns {
+			compressed += column.MetaData.TotalCompressedSize
+			if codec := column.MetaData.Codec.String(); !seen[codec] {
+				seen[codec] = true
+				codecs = append(codecs, codec)
+			}
+		}
+		fmt.Fprintf(&s, "  %4d  %d rows, %s (%s uncompressed)\n",
+			i+1, rg.NumRows, formatSize(compressed), formatSize(rg.TotalByteSize))
+	}
+
+	header := fmt.Sprintf("Format       Parquet\nRows         %d\nRow groups   %d\nColumns      %d\nCompression  %s\n",
+		file.NumRows(), len(meta.RowGroups), len(file.Schema().Fields()), strings.Join(codecs, ", "))
+	if meta.CreatedBy != "" {
+		header += fmt.Sprintf("Created by   %s\n", meta.CreatedBy)
+	}
+	summary := header + "\n" + s.String() + "\nSchema\n" + file.Schema().String() + "\n"
+
+	fields := file.Schema().Fields()
+	t := &tableView{header: make([]string, len(fields)), total: file.NumRows()}
+	for i, field := range fields {
+		t.header[i] = field.Name()
+	}
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+	rows := make([]parquet.Row, 1)
+	for len(t.rows) < maxRows {
+		n, err := reader.ReadRows(rows)
+		if n == 0 && errors.Is(err, io.EOF) {
+			break
+		}
+		if n == 0 {
+			t.err = err
+			break
+		}
+		record := parquetRecord(file.Schema(), rows[0])
+		for _, field := range fields {
+			record[field.Name()] = parquetValue(field, record[field.Name()])
+		}
+		t.rows = append(t.rows, recordRow(len(t.rows)+1, t.header, record))
+	}
+	t.complete = int64(len(t.rows)) == t.total
+	return summary, t, nil
+}
+
+// previewAvro reads the header of an Avro object container file, counts
+// its rows and decodes the first of them
+func previewAvro(r *backend.RangeReaderAt, maxRows int) (string, *tableView, error) {
+	decoder, err := ocf.NewDecoder(io.NewSectionReader(r, 0, r.Size()))
+	if err != nil {
+		return "", nil, err
+	}
+
+	codec := string(decoder.Metadata()["avro.codec"])
+	if codec == "" {
+		codec = "null"
+	}
+	rows, blocks, complete, err := countAvroRows(r, r.Size(), avroScanSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	count := fmt.Sprintf("%d", rows)
+	if !complete {
+		count = fmt.Sprintf("at least %d (counted in the first %s)", rows, formatSize(avroScanSize))
+	}
+	var schema bytes.Buffer
+	if err := json.Indent(&schema, decoder.Metadata()["avro.schema"], "", "  "); err != nil {
+		schema.Reset()
+		schema.Write(decoder.Metadata()["avro.schema"])
+	}
+	summary := fmt.Sprintf("Format       Avro object container file\nRows         %s\nBlocks       %d\nCompression  %s\n\nSchema\n%s\n",
+		count, blocks, codec, schema.String())
+
+	t := &tableView{total: rows, atLeast: !complete}
+	record, isRecord := decoder.Schema().(*avro.RecordSchema)
+	if isRecord {
+		for _, field := range record.Fields() {
+			t.header = append(t.header, field.Name())
+		}
+	} else {
+		t.header = []string{"value"}
+	}
+
+	for len(t.rows) < maxRows && decoder.HasNext() {
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			t.err = err
+			break
+		}
+		row, ok := value.(map[string]any)
+		if !isRecord || !ok {
+			row = map[string]any{"value": value}
+		}
+		t.rows = append(t.rows, recordRow(len(t.rows)+1, t.header, row))
+	}
+	if t.err == nil {
+		t.err = decoder.Error()
+	}
+	t.complete = t.err == nil && !decoder.HasNext()
+	return summary, t, nil
+}
+
+// countAvroRows sums the row counts of an Avro file's blocks. Every block
+// starts with its row count and size, so the blocks are skipped without
+// being read. Counting stops after limit bytes, and complete reports
+// whether every block was counted.
+func countAvroRows(r io.ReaderAt, size, limit int64) (rows int64, blocks int, complete bool, err error) {
+	header := &countingReader{r: bufio.NewReader(io.NewSectionReader(r, 0, size))}
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(header, magic); err != nil {
+		return 0, 0, false, err
+	}
+	if string(magic) != "Obj\x01" {
+		return 0, 0, false, errors.New("not an Avro object container file")
+	}
+
+	// The header metadata is a map of strings to bytes written in blocks
+	for {
+		count, err := binary.ReadVarint(header)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			count = -count
+			if _, err := binary.ReadVarint(header); err != nil {
+				return 0, 0, false, err
+			}
+		}
+		for i := int64(0); i < 2*count; i++ {
+			if err := header.skipBytes(); err != nil {
+				return 0, 0, false, err
+			}
+		}
+	}
+	if _, err := io.ReadFull(header, make([]byte, 16)); err != nil {
+		return 0, 0, false, err
+	}
+
+	// Each block is its row count, its size, its data and a sync marker
+	offset := header.n
+	for offset < size {
+		if offset > limit {
+			return rows, blocks, false, nil
+		}
+		block := &countingReader{r: bufio.NewReaderSize(io.NewSectionReader(r, offset, size-offset), 32)}
+		count, err := binary.ReadVarint(block)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		length, err := binary.ReadVarint(block)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if count < 0 || length < 0 {
+			return 0, 0, false, errors.New("malformed block header")
+		}
+		rows += count
+		blocks++
+		offset += block.n + length + 16
+	}
+	return rows, blocks, true, nil
+}
+
+// countingReader counts the bytes read from a buffered reader
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// skipBytes skips a length-prefixed Avro string or bytes value
+func (c *countingReader) skipBytes() error {
+	length, err := binary.ReadVarint(c)
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		return errors.New("malformed header")
+	}
+	n, err := c.r.Discard(int(length))
+	c.n += int64(n)
+	return err
+}
+
+// parquetRecord reconstructs a row as a record. Rows parquet-go cannot
+// reconstruct, such as ones with empty maps, fall back to the values of
+// the leaf columns below each field.
+func parquetRecord(schema *parquet.Schema, row parquet.Row) (record map[string]any) {
+	defer func() {
+		if recover() != nil {
+			record = leafRecord(schema, row)
+		}
+	}()
+
+	record = make(map[string]any)
+	if err := schema.Reconstruct(&record, row); err != nil {
+		return leafRecord(schema, row)
+	}
+	return record
+}
+
+// leafRecord maps every top-level field onto the non-null values of the
+// leaf columns below it. Values of leaf fields are kept as they are.
+func leafRecord(schema *parquet.Schema, row parquet.Row) map[string]any {
+	columns := schema.Columns()
+	values := make(map[string][]any)
+	for _, v := range row {
+		if v.IsNull() || v.Column() >= len(columns) {
+			continue
+		}
+		name := columns[v.Column()][0]
+		values[name] = append(values[name], parquetLeaf(v))
+	}
+
+	record := make(map[string]any, len(values))
+	for _, field := range schema.Fields() {
+		vs := values[field.Name()]
+		switch {
+		case len(vs) == 1 && field.Leaf() && !field.Repeated():
+			record[field.Name()] = vs[0]
+		case len(vs) > 0:
+			record[field.Name()] = vs
+		}
+	}
+	return record
+}
+
+// parquetLeaf returns the Go value of a leaf column value
+func parquetLeaf(v parquet.Value) any {
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean()
+	case parquet.Int32:
+		return v.Int32()
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Float:
+		return v.Float()
+	case parquet.Double:
+		return v.Double()
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return v.ByteArray()
+	}
+	return v.String()
+}
+
+// parquetValue converts timestamps and dates, which Parquet stores as
+// integers, to times
+func parquetValue(field parquet.Field, v any) any {
+	if !field.Leaf() || field.Type().LogicalType() == nil {
+		return v
+	}
+	logical := field.Type().LogicalType()
+	switch n := v.(type) {
+	case int64:
+		if ts := logical.Timestamp; ts != nil {
+			switch {
+			case ts.Unit.Millis != nil:
+				return time.UnixMilli(n).UTC()
+			case ts.Unit.Micros != nil:
+				return time.UnixMicro(n).UTC()
+			default:
+				return time.Unix(0, n).UTC()
+			}
+		}
+	case int32:
+		if logical.Date != nil {
+			return time.Unix(int64(n)*24*60*60, 0).UTC().Format(time.DateOnly)
+		}
+	}
+	return v
+}
+
+// recordRow formats a decoded record as a table row in header order
+func recordRow(line int, header []string, record map[string]any) tableRow {
+	fields := make([]string, len(header))
+	for i, name := range header {
+		fields[i] = cellString(record[name])
+	}
+	return tableRow{line: line, fields: fields}
+}
+
+// cellString formats a decoded value for a table cell. Nested values are
+// shown as compact JSON.
+func cellString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return bytesString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case *big.Rat:
+		return v.FloatString(6)
+	case fmt.Stringer:
+		return v.String()
+	case map[string]any, []any:
+		data, err := json.Marshal(jsonValue(v))
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// bytesString shows bytes as text when they are valid UTF-8, since
+// Parquet stores strings as byte arrays, and as base64 otherwise
+func bytesString(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// jsonValue converts the values of nested records so that they marshal
+// the way cellString shows them
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = jsonValue(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = jsonValue(e)
+		}
+		return out
+	case []byte:
+		return bytesString(v)
+	case time.Time, *big.Rat, fmt.Stringer:
+		return cellString(v)
+	}
+	return v
+}
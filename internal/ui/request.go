@@ -0,0 +1,29 @@
+package ui
+
+import "context"
+
+// request tracks an in-flight operation. Each request has its own context
+// and a generation ID so that results of superseded requests can be dropped.
+type request struct {
+	id     int
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newRequest creates the first request of a generation sequence
+func newRequest() request {
+	ctx, cancel := context.WithCancel(context.Background())
+	return request{id: 1, ctx: ctx, cancel: cancel}
+}
+
+// next cancels the request and returns its successor
+func (r request) next() request {
+	r.cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	return request{id: r.id + 1, ctx: ctx, cancel: cancel}
+}
+
+// canceled reports whether the request has been cancelled
+func (r request) canceled() bool {
+	return r.ctx.Err() != nil
+}
@@ -0,0 +1,306 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxMatches is the number of matches a search stops at
+const maxMatches = 10000
+
+var (
+	searchMatchStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#000000")).
+				Background(lipgloss.Color("#E5C07B"))
+
+	searchCurrentStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#000000")).
+				Background(lipgloss.Color("#FF8700"))
+)
+
+// textMatch is a match in a line of the viewed text, from byte start up
+// to byte end
+type textMatch struct {
+	line  int
+	start int
+	end   int
+}
+
+// textSearch holds the matches of a regular expression in the viewed text
+// and the match that was stepped to
+type textSearch struct {
+	pattern    string
+	ignoreCase bool
+	matches    []textMatch
+	more       bool
+	current    int
+}
+
+// newTextSearch finds the matches of pattern in lines, stopping after
+// maxMatches. Empty matches are left out since they cannot be shown.
+func newTextSearch(pattern string, ignoreCase bool, lines []string) (*textSearch, error) {
+	re, err := compilePattern(pattern, ignoreCase)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &textSearch{pattern: pattern, ignoreCase: ignoreCase}
+	for i, line := range lines {
+		for _, loc := range re.FindAllStringIndex(line, maxMatches+1-len(s.matches)) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if len(s.matches) == maxMatches {
+				s.more = true
+				return s, nil
+			}
+			s.matches = append(s.matches, textMatch{line: i, start: loc[0], end: loc[1]})
+		}
+	}
+	return s, nil
+}
+
+// compilePattern compiles a search pattern, matching regardless of case if
+// ignoreCase is set
+func compilePattern(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if ignoreCase {
+		re = regexp.MustCompile("(?i)" + pattern)
+	}
+	return re, nil
+}
+
+// redo searches lines again for the same pattern, e.g. after they changed.
+// The new search starts at the first match at or below line.
+func (s *textSearch) redo(lines []string, line int) *textSearch {
+	search, err := newTextSearch(s.pattern, s.ignoreCase, lines)
+	if err != nil {
+		return s
+	}
+	search.seek(line)
+	return search
+}
+
+// seek makes the first match at or below line current, wrapping around to
+// the first match
+func (s *textSearch) seek(line int) {
+	s.current = sort.Search(len(s.matches), func(i int) bool { return s.matches[i].line >= line })
+	if s.current == len(s.matches) {
+		s.current = 0
+	}
+}
+
+// step moves to the next match, or the previous one if delta is negative,
+// wrapping around at either end
+func (s *textSearch) step(delta int) {
+	if n := len(s.matches); n > 0 {
+		s.current = ((s.current+delta)%n + n) % n
+	}
+}
+
+// mark returns lines with the lines holding matches replaced by their
+// plain text with the matches highlighted. Syntax highlighting is dropped
+// on those lines so that the colours do not clash.
+func (s *textSearch) mark(lines, plain []string) []string {
+	marked := append([]string(nil), lines...)
+	for i := 0; i < len(s.matches); {
+		line := s.matches[i].line
+		if line < len(marked) {
+			marked[line], _ = s.markLine(line, plain[line])
+		}
+		for i < len(s.matches) && s.matches[i].line == line {
+			i++
+		}
+	}
+	return marked
+}
+
+// markLine returns plain, the text of line, with its matches highlighted.
+// It reports false if the line holds no match.
+func (s *textSearch) markLine(line int, plain string) (string, bool) {
+	i := sort.Search(len(s.matches), func(i int) bool { return s.matches[i].line >= line })
+	if i == len(s.matches) || s.matches[i].line != line {
+		return plain, false
+	}
+
+	var b strings.Builder
+	pos := 0
+	for ; i < len(s.matches) && s.matches[i].line == line; i++ {
+		match := s.matches[i]
+		style := searchMatchStyle
+		if i == s.current {
+			style = searchCurrentStyle
+		}
+		b.WriteString(plain[pos:match.start])
+		b.WriteString(style.Render(plain[match.start:match.end]))
+		pos = match.end
+	}
+	b.WriteString(plain[pos:])
+	return b.String(), true
+}
+
+// onLine reports whether line holds a match and whether it holds the
+// current one
+func (s *textSearch) onLine(line int) (bool, bool) {
+	i := sort.Search(len(s.matches), func(i int) bool { return s.matches[i].line >= line })
+	if i == len(s.matches) || s.matches[i].line != line {
+		return false, false
+	}
+	return true, s.matches[s.current].line == line
+}
+
+// describe summarises the search for the status bar
+func (s *textSearch) describe() string {
+	label := searchLabel(s.pattern, s.ignoreCase)
+	switch {
+	case len(s.matches) == 0:
+		return fmt.Sprintf("[no matches for %s]", label)
+	case s.more:
+		return fmt.Sprintf("[match %d of %d+ for %s]", s.current+1, len(s.matches), label)
+	}
+	return fmt.Sprintf("[match %d of %d for %s]", s.current+1, len(s.matches), label)
+}
+
+// searchLabel returns how a search is shown in the status bar
+func searchLabel(pattern string, ignoreCase bool) string {
+	label := "/" + pattern + "/"
+	if ignoreCase {
+		label += "i"
+	}
+	return label
+}
+
+// searchPrompt returns the prompt of the search input
+func searchPrompt(ignoreCase bool) string {
+	if ignoreCase {
+		return "Search (ignore case): "
+	}
+	return "Search: "
+}
+
+// startSearch opens the search prompt, filled with the last pattern
+func (m Model) startSearch() (Model, tea.Cmd) {
+	m.searching = true
+	m.queryInput = textinput.New()
+	m.queryInput.Prompt = searchPrompt(m.ignoreCase)
+	m.queryInput.Placeholder = "regular expression, tab toggles case"
+	if pattern := m.searchPattern(); pattern != "" {
+		m.queryInput.SetValue(pattern)
+		m.queryInput.CursorEnd()
+	}
+	return m, m.queryInput.Focus()
+}
+
+// searchPattern returns the pattern last searched for in the shown view
+func (m Model) searchPattern() string {
+	if p := m.file.paged; p != nil {
+		if p.search != nil {
+			return p.search.pattern
+		}
+		return ""
+	}
+	if s := m.shownSearch(); s != nil {
+		return s.pattern
+	}
+	return ""
+}
+
+// shownSearch returns the search of the text, JSON tree or table shown, or
+// nil
+func (m Model) shownSearch() *textSearch {
+	switch {
+	case m.file.hex != nil || m.file.paged != nil:
+		return nil
+	case m.file.structured && m.file.table != nil:
+		return m.file.table.search
+	case m.file.structured:
+		return m.file.tree.search
+	}
+	return m.file.search
+}
+
+// updateSearch handles the search prompt of the text viewer
+func (m Model) updateSearch(msg tea.KeyMsg) (Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "esc":
+		m.searching = false
+		m.statusMsg = m.viewerStatus()
+		return m, nil
+	case "tab":
+		m.ignoreCase = !m.ignoreCase
+		m.queryInput.Prompt = searchPrompt(m.ignoreCase)
+		return m, nil
+	case "enter":
+		m.searching = false
+		pattern := m.queryInput.Value()
+		switch {
+		case m.file.paged != nil:
+			return m.searchPaged(pattern)
+		case m.file.structured:
+			return m.searchRows(pattern), nil
+		}
+		if pattern == "" {
+			m.file.search = nil
+			return m.showMatch(), nil
+		}
+
+		search, err := newTextSearch(pattern, m.ignoreCase, m.file.plainLines())
+		if err != nil {
+			m.statusMsg = fmt.Sprintf("Invalid pattern: %v", err)
+			return m, nil
+		}
+		search.seek(m.viewport.YOffset)
+		m.file.search = search
+		return m.showMatch(), nil
+	}
+
+	var cmd tea.Cmd
+	m.queryInput, cmd = m.queryInput.Update(msg)
+	return m, cmd
+}
+
+// showMatch renders the text with the matches of the search highlighted
+// and scrolls the current match into view
+func (m Model) showMatch() Model {
+	m.viewport.SetContent(m.file.render())
+	if s := m.file.search; s != nil && len(s.matches) > 0 {
+		_, height := m.viewerSize()
+		line := s.matches[s.current].line
+		if line < m.viewport.YOffset || line >= m.viewport.YOffset+height {
+			m.viewport.SetYOffset(line - height/3)
+		}
+	}
+	m.statusMsg = m.viewerStatus()
+	return m
+}
+
+// searchRows searches the rendered rows of the JSON tree or table and
+// moves the cursor to the first match at or below it. An empty pattern
+// clears the search.
+func (m Model) searchRows(pattern string) Model {
+	width, height := m.viewerSize()
+	var err error
+	if t := m.file.table; t != nil {
+		err = t.find(pattern, m.ignoreCase, width, height-2)
+	} else {
+		err = m.file.tree.find(pattern, m.ignoreCase, height)
+	}
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("Invalid pattern: %v", err)
+		return m
+	}
+	m.statusMsg = m.viewerStatus()
+	return m
+}
@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fernandoabolafio/lazybucket/internal/memory"
+)
+
+// viewObject stores data as the object called name in photos and views it
+func viewObject(t *testing.T, m Model, b *memory.Backend, name, data string) Model {
+	t.Helper()
+	b.PutObject("photos", name, memory.Object{Data: []byte(data)})
+	m = pressAndRun(m, "r")
+	m = pressAndRun(selectItem(t, m, name), "v")
+	if !m.viewingFile {
+		t.Fatalf("%s did not open, status %q", name, m.statusMsg)
+	}
+	return m
+}
+
+// search searches the viewed file for pattern
+func search(m Model, pattern string) Model {
+	m, _ = press(m, "/")
+	m.queryInput.SetValue(pattern)
+	return pressAndRun(m, "enter")
+}
+
+func TestSearchJSONTree(t *testing.T) {
+	b := testStore()
+	m := newTestModel(t, b, "photos")
+	m = viewObject(t, m, b, "users.json", `[
+		{"name": "ada", "role": "admin"},
+		{"name": "bob", "role": "viewer"},
+		{"name": "cy", "role": "admin"}
+	]`)
+	tree := m.file.tree
+
+	m = search(m, `"admin"`)
+	if got, want := m.statusMsg, "[match 1 of 2 for /\"admin\"/]"; !strings.HasSuffix(got, want) {
+		t.Errorf("status = %q, want it to end with %q", got, want)
+	}
+	if got := renderJSONLine(tree.lines[tree.cursor], false); !strings.Contains(got, `"role": "admin"`) {
+		t.Errorf("cursor is on %q, want the first admin role", got)
+	}
+
+	m, _ = press(m, "n")
+	second := tree.cursor
+	if got := renderJSONLine(tree.lines[second], false); !strings.Contains(got, `"role": "admin"`) {
+		t.Errorf("n moved the cursor to %q", got)
+	}
+
+	// Folding hides the first match and searches the rendered lines again
+	tree.cursor = 1
+	m, _ = press(m, "enter")
+	if got := len(tree.search.matches); got != 1 {
+		t.Errorf("%d matches after folding the first record, want 1", got)
+	}
+
+	m, _ = press(m, "esc")
+	if tree.search != nil {
+		t.Error("esc did not clear the search")
+	}
+	if !m.viewingFile {
+		t.Error("esc left the viewer instead of clearing the search")
+	}
+}
+
+func TestSearchTable(t *testing.T) {
+	b := testStore()
+	m := newTestModel(t, b, "photos")
+	m = viewObject(t, m, b, "users.csv", "name,city,team\nada,Paris,red\nbob,Oslo,blue\ncy,Lima,red\ndee,Pisa,green\n")
+	table := m.file.table
+
+	m = search(m, "^P")
+	if got, want := m.statusMsg, "[match 1 of 2 for /^P/]"; !strings.HasSuffix(got, want) {
+		t.Errorf("status = %q, want it to end with %q", got, want)
+	}
+	if table.cursor != 0 || table.column != 1 {
+		t.Errorf("selected row %d column %d, want row 0 column 1", table.cursor, table.column)
+	}
+
+	m, _ = press(m, "n")
+	if table.cursor != 3 || table.column != 1 {
+		t.Errorf("n selected row %d column %d, want row 3 column 1", table.cursor, table.column)
+	}
+
+	// Every match in a cell is stepped over at once, starting from the cursor
+	m = search(m, "e")
+	var cells []string
+	for range table.search.matches {
+		cells = append(cells, field(table.rows[table.cursor].fields, table.column))
+		m, _ = press(m, "n")
+	}
+	if got, want := strings.Join(cells, " "), "dee green red blue red"; got != want {
+		t.Errorf("stepped through %q, want %q", got, want)
+	}
+
+	// Sorting moves the matching cells along with their rows
+	m = search(m, "Oslo")
+	table.column = 0
+	m, _ = press(m, "s")
+	m, _ = press(m, "s")
+	m, _ = press(m, "n")
+	if got := field(table.rows[table.cursor].fields, table.column); got != "Oslo" {
+		t.Errorf("after sorting, n selected %q, want Oslo", got)
+	}
+}
+
+// pagedText returns about size bytes of numbered lines, with needle on the
+// lines given
+func pagedText(size int, needles ...int) string {
+	var b strings.Builder
+	for i := 1; b.Len() < size; i++ {
+		line := fmt.Sprintf("line %07d", i)
+		for _, n := range needles {
+			if i == n {
+				line += " needle"
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func TestSearchPaged(t *testing.T) {
+	b := testStore()
+	m := newWindowModel(t, b, 1<<20)
+
+	// The window holds two chunks, so the second needle is far beyond it
+	// and the first one is dropped by the time it is found
+	m = viewObject(t, m, b, "big.log", pagedText(6<<20, 20, 400000))
+	p := m.file.paged
+	if p == nil {
+		t.Fatal("big.log is not paged")
+	}
+
+	shown := func(m Model) string {
+		width, height := m.viewerSize()
+		return p.view(width, height, false)
+	}
+
+	m = search(m, "needle")
+	if !strings.Contains(shown(m), "line 0000020 needle") {
+		t.Fatalf("first match is not shown, status %q", m.statusMsg)
+	}
+
+	m = pressAndRun(m, "n")
+	if !strings.Contains(shown(m), "line 0400000 needle") {
+		t.Fatalf("second match is not shown, status %q", m.statusMsg)
+	}
+	if got, want := m.statusMsg, "[match for /needle/]"; !strings.HasSuffix(got, want) {
+		t.Errorf("status = %q, want it to end with %q", got, want)
+	}
+	if p.start == 0 {
+		t.Error("the start of the object is still held, so nothing was paged")
+	}
+
+	m = pressAndRun(m, "n")
+	if got, want := m.statusMsg, "[no more matches for /needle/]"; !strings.HasSuffix(got, want) {
+		t.Errorf("status = %q, want it to end with %q", got, want)
+	}
+
+	m = pressAndRun(m, "N")
+	if !strings.Contains(shown(m), "line 0000020 needle") {
+		t.Fatalf("N did not page back to the first match, status %q", m.statusMsg)
+	}
+
+	m = search(m, "missing")
+	if got, want := m.statusMsg, "[no matches for /missing/]"; !strings.HasSuffix(got, want) {
+		t.Errorf("status = %q, want it to end with %q", got, want)
+	}
+
+	m, _ = press(m, "esc")
+	if p.search != nil {
+		t.Error("esc did not clear the search")
+	}
+}
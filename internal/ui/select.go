@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+)
+
+// markable reports whether an item can be marked. The ".." entry never is.
+func markable(item ListItem) bool {
+	return item.item.Name != ".."
+}
+
+// toggleMark marks or unmarks the highlighted item and moves the cursor
+// down so that runs of items can be marked quickly
+func (m Model) toggleMark() Model {
+	selected, ok := m.selectedItem()
+	if !ok || !markable(selected) {
+		return m
+	}
+
+	selected.marked = !selected.marked
+	m.list.SetItem(m.list.Index(), selected)
+	m.list.CursorDown()
+	m.statusMsg = m.markedStatus()
+	return m
+}
+
+// setMarks applies mark to every loaded item
+func (m Model) setMarks(mark func(ListItem) bool) Model {
+	items := m.list.Items()
+	updated := make([]list.Item, len(items))
+	for i, it := range items {
+		item := it.(ListItem)
+		if markable(item) {
+			item.marked = mark(item)
+		}
+		updated[i] = item
+	}
+	m.list.SetItems(updated)
+	m.statusMsg = m.markedStatus()
+	return m
+}
+
+// markAll marks every loaded item, or clears the marks if all of them are
+// already marked
+func (m Model) markAll() Model {
+	all := m.markedCount() == m.markableCount()
+	return m.setMarks(func(ListItem) bool { return !all })
+}
+
+// invertMarks marks every unmarked item and unmarks the rest
+func (m Model) invertMarks() Model {
+	return m.setMarks(func(item ListItem) bool { return !item.marked })
+}
+
+// markedItems returns the marked items in list order
+func (m Model) markedItems() []backend.Item {
+	var items []backend.Item
+	for _, it := range m.list.Items() {
+		if item := it.(ListItem); item.marked {
+			items = append(items, item.item)
+		}
+	}
+	return items
+}
+
+// markedCount returns the number of marked items
+func (m Model) markedCount() int {
+	return len(m.markedItems())
+}
+
+// markableCount returns the number of loaded items that can be marked
+func (m Model) markableCount() int {
+	n := 0
+	for _, it := range m.list.Items() {
+		if markable(it.(ListItem)) {
+			n++
+		}
+	}
+	return n
+}
+
+// targets returns the items an action applies to: the marked items if
+// there are any, otherwise the highlighted item
+func (m Model) targets() []backend.Item {
+	if marked := m.markedItems(); len(marked) > 0 {
+		return marked
+	}
+	selected, ok := m.selectedItem()
+	if !ok || !markable(selected) {
+		return nil
+	}
+	return []backend.Item{selected.item}
+}
+
+// markedStatus describes how many items are marked
+func (m Model) markedStatus() string {
+	return fmt.Sprintf("%d of %d items marked", m.markedCount(), m.markableCount())
+}
+
+// objectsOnly filters out folders and buckets
+func objectsOnly(items []backend.Item) (objects []backend.Item, skipped int) {
+	for _, item := range items {
+		if item.IsDir {
+			skipped++
+			continue
+		}
+		objects = append(objects, item)
+	}
+	return objects, skipped
+}
@@ -0,0 +1,455 @@
+package ui
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// tableRows is the number of rows read from CSV and TSV objects, so
+	// that large files open quickly
+	tableRows = 1000
+
+	// tableColumnWidth is the width columns are truncated to unless they
+	// are expanded
+	tableColumnWidth = 32
+)
+
+var (
+	tableHeaderStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#4A86CF"))
+
+	tableSelectedColumnStyle = lipgloss.NewStyle().
+					Bold(true).
+					Foreground(lipgloss.Color("#FFFFFF")).
+					Background(lipgloss.Color("#4A86CF"))
+)
+
+// tableDelimiter returns the field delimiter of a CSV or TSV object, or 0
+// if the object is not tabular
+func tableDelimiter(name, contentType string) rune {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return ','
+	case ".tsv", ".tab":
+		return '\t'
+	}
+	switch mediaType, _, _ := mime.ParseMediaType(contentType); mediaType {
+	case "text/csv":
+		return ','
+	case "text/tab-separated-values":
+		return '\t'
+	}
+	return 0
+}
+
+// tableView shows the first rows of a CSV, TSV, Parquet or Avro object as
+// a table
+type tableView struct {
+	header []string
+	rows   []tableRow
+
+	// complete is set when every row of the object was read, and err when
+	// reading stopped early because of malformed input
+	complete bool
+	err      error
+
+	// total is the number of rows in the object when its metadata tells,
+	// or a lower bound if atLeast is set
+	total   int64
+	atLeast bool
+
+	column   int
+	first    int
+	cursor   int
+	offset   int
+	sortCol  int
+	sortDesc bool
+	sorted   bool
+	expanded bool
+
+	// search holds the cells matching the last search, numbered row by
+	// row in the order the rows are shown
+	search *textSearch
+}
+
+// tableRow is a row together with its position in the object
+type tableRow struct {
+	line   int
+	fields []string
+}
+
+// readTable reads the header and up to maxRows rows from r
+func readTable(r io.Reader, delimiter rune, maxRows int) (*tableView, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the file is empty")
+		}
+		return nil, err
+	}
+
+	t := &tableView{header: header}
+	for len(t.rows) < maxRows {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			t.complete = true
+			break
+		}
+		if err != nil {
+			t.err = err
+			break
+		}
+		t.rows = append(t.rows, tableRow{line: len(t.rows) + 1, fields: fields})
+	}
+	return t, nil
+}
+
+// columns returns the number of columns of the widest row
+func (t *tableView) columns() int {
+	n := len(t.header)
+	for _, row := range t.rows {
+		n = max(n, len(row.fields))
+	}
+	return n
+}
+
+// widths returns the display width of every column
+func (t *tableView) widths() []int {
+	widths := make([]int, t.columns())
+	measure := func(fields []string) {
+		for i, field := range fields {
+			widths[i] = max(widths[i], runewidth.StringWidth(field))
+		}
+	}
+	measure(t.headerLabels())
+	for _, row := range t.rows {
+		measure(row.fields)
+	}
+	if !t.expanded {
+		for i := range widths {
+			widths[i] = min(widths[i], tableColumnWidth)
+		}
+	}
+	return widths
+}
+
+// headerLabels returns the header with an arrow on the sorted column
+func (t *tableView) headerLabels() []string {
+	if !t.sorted {
+		return t.header
+	}
+
+	header := append([]string(nil), t.header...)
+	for len(header) <= t.sortCol {
+		header = append(header, "")
+	}
+	arrow := " ↑"
+	if t.sortDesc {
+		arrow = " ↓"
+	}
+	header[t.sortCol] += arrow
+	return header
+}
+
+// sortBy sorts the rows by the selected column, ascending first, then
+// descending, then back in file order. Columns holding only numbers are
+// sorted numerically.
+func (t *tableView) sortBy() {
+	switch {
+	case !t.sorted || t.sortCol != t.column:
+		t.sorted, t.sortCol, t.sortDesc = true, t.column, false
+	case !t.sortDesc:
+		t.sortDesc = true
+	default:
+		t.sorted = false
+	}
+
+	col := t.sortCol
+	numeric := true
+	for _, row := range t.rows {
+		if v := field(row.fields, col); v != "" {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				numeric = false
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(t.rows, func(i, j int) bool {
+		a, b := t.rows[i], t.rows[j]
+		if !t.sorted {
+			return a.line < b.line
+		}
+		if t.sortDesc {
+			a, b = b, a
+		}
+		if numeric {
+			x, _ := strconv.ParseFloat(field(a.fields, col), 64)
+			y, _ := strconv.ParseFloat(field(b.fields, col), 64)
+			return x < y
+		}
+		return field(a.fields, col) < field(b.fields, col)
+	})
+
+	// Sorting renumbers the cells the matches refer to
+	if t.search != nil {
+		t.search = t.search.redo(t.cells(), t.cursor*t.columns())
+	}
+}
+
+// cells returns the fields of every row, padded to the same number of
+// columns, so that cell i is in row i/columns and column i%columns
+func (t *tableView) cells() []string {
+	columns := t.columns()
+	cells := make([]string, 0, len(t.rows)*columns)
+	for _, row := range t.rows {
+		for i := 0; i < columns; i++ {
+			cells = append(cells, field(row.fields, i))
+		}
+	}
+	return cells
+}
+
+// find searches the cells for pattern and selects the first matching cell
+// at or below the cursor. An empty pattern clears the search.
+func (t *tableView) find(pattern string, ignoreCase bool, width, height int) error {
+	if pattern == "" {
+		t.search = nil
+		return nil
+	}
+	search, err := newTextSearch(pattern, ignoreCase, t.cells())
+	if err != nil {
+		return err
+	}
+
+	// Cells are highlighted as a whole, so the search steps from cell to
+	// cell rather than through every match in a cell
+	matches := search.matches[:0]
+	for _, match := range search.matches {
+		if n := len(matches); n == 0 || matches[n-1].line != match.line {
+			matches = append(matches, match)
+		}
+	}
+	search.matches = matches
+	search.seek(t.cursor * t.columns())
+	t.search = search
+	t.showMatch(width, height)
+	return nil
+}
+
+// step moves to the next matching cell, or the previous one if delta is
+// negative
+func (t *tableView) step(delta, width, height int) {
+	t.search.step(delta)
+	t.showMatch(width, height)
+}
+
+// showMatch selects the cell of the current match
+func (t *tableView) showMatch(width, height int) {
+	if s := t.search; s != nil && len(s.matches) > 0 {
+		cell := s.matches[s.current].line
+		columns := t.columns()
+		t.move(cell/columns-t.cursor, height)
+		t.moveColumn(cell%columns-t.column, width)
+	}
+}
+
+// field returns the ith field of a row, or "" if the row is too short
+func field(fields []string, i int) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return ""
+}
+
+// move moves the row cursor by delta, keeping it within a window of height
+// rows
+func (t *tableView) move(delta, height int) {
+	t.cursor = max(0, min(t.cursor+delta, len(t.rows)-1))
+	height = max(1, height)
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+height {
+		t.offset = t.cursor - height + 1
+	}
+}
+
+// moveColumn selects another column, scrolling horizontally so that it
+// stays visible within width
+func (t *tableView) moveColumn(delta, width int) {
+	t.column = max(0, min(t.column+delta, t.columns()-1))
+	if t.column < t.first {
+		t.first = t.column
+	}
+
+	// Scroll right until the selected column fits
+	widths := t.widths()
+	for t.first < t.column {
+		used := t.gutterWidth()
+		for i := t.first; i <= t.column; i++ {
+			used += widths[i] + 3
+		}
+		if used <= width {
+			break
+		}
+		t.first++
+	}
+}
+
+// gutterWidth returns the width of the row number column
+func (t *tableView) gutterWidth() int {
+	return len(strconv.Itoa(len(t.rows))) + 3
+}
+
+// view renders the header and the window of rows starting at the scroll
+// offset, from the first visible column on
+func (t *tableView) view(width, height int) string {
+	widths := t.widths()
+	gutter := t.gutterWidth() - 3
+
+	// row is the index of the row rendered, or -1 for the header
+	renderRow := func(number string, fields []string, row int) string {
+		header := row < 0
+		var s strings.Builder
+		s.WriteString(lineNumberStyle.Render(fmt.Sprintf("%*s │ ", gutter, number)))
+		for i := t.first; i < len(widths); i++ {
+			cell := runewidth.FillRight(runewidth.Truncate(field(fields, i), widths[i], "…"), widths[i])
+			var matched, current bool
+			if t.search != nil && !header {
+				matched, current = t.search.onLine(row*len(widths) + i)
+			}
+			switch {
+			case header && i == t.column:
+				cell = tableSelectedColumnStyle.Render(cell)
+			case header:
+				cell = tableHeaderStyle.Render(cell)
+			case current:
+				cell = searchCurrentStyle.Render(cell)
+			case matched:
+				cell = searchMatchStyle.Render(cell)
+			}
+			s.WriteString(cell)
+			if i < len(widths)-1 {
+				s.WriteString(lineNumberStyle.Render(" │ "))
+			}
+		}
+		return lipgloss.NewStyle().MaxWidth(width).Render(s.String())
+	}
+
+	lines := []string{renderRow("", t.headerLabels(), -1)}
+	rule := lineNumberStyle.Render(strings.Repeat("─", max(1, width)))
+	lines = append(lines, rule)
+
+	for i := t.offset; i < len(t.rows) && len(lines) < height; i++ {
+		line := renderRow(strconv.Itoa(t.rows[i].line), t.rows[i].fields, i)
+		if i == t.cursor {
+			line = jsonCursorStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tableStatus describes the table and how many of the object's rows it
+// holds
+func (m Model) tableStatus() string {
+	t := m.file.table
+	rows := fmt.Sprintf("%d rows", len(t.rows))
+	switch {
+	case t.err != nil:
+		rows = fmt.Sprintf("first %d rows, stopped at malformed row: %v", len(t.rows), t.err)
+	case !t.complete && t.atLeast:
+		rows = fmt.Sprintf("first %d of at least %d rows", len(t.rows), t.total)
+	case !t.complete && t.total > 0:
+		rows = fmt.Sprintf("first %d of %d rows", len(t.rows), t.total)
+	case !t.complete:
+		rows = fmt.Sprintf("first %d rows", len(t.rows))
+	}
+
+	status := fmt.Sprintf("Viewing %s (table, %s, %d columns", m.file.name, rows, t.columns())
+	if t.columns() > 0 {
+		status += fmt.Sprintf(", column %d: %s", t.column+1, field(t.header, t.column))
+	}
+	return status + ")"
+}
+
+// updateTableView handles keys while the table is shown
+func (m Model) updateTableView(msg tea.KeyMsg) (Model, tea.Cmd) {
+	t := m.file.table
+	width, height := m.viewerSize()
+	rows := height - 2
+
+	switch {
+	case key.Matches(msg, m.keyMap.Quit):
+		return m.quit()
+	case key.Matches(msg, m.keyMap.Back):
+		m.viewingFile = false
+		return m, nil
+	case key.Matches(msg, m.keyMap.Structured):
+		m.file.structured = false
+	case key.Matches(msg, m.keyMap.Search):
+		return m.startSearch()
+	case key.Matches(msg, m.keyMap.NextMatch), key.Matches(msg, m.keyMap.PrevMatch):
+		if t.search == nil {
+			m.statusMsg = "Nothing searched yet, press / to search"
+			return m, nil
+		}
+		delta := 1
+		if key.Matches(msg, m.keyMap.PrevMatch) {
+			delta = -1
+		}
+		t.step(delta, width, rows)
+	case key.Matches(msg, m.keyMap.Cancel) && t.search != nil:
+		// Clear the highlighted cells
+		t.search = nil
+	default:
+		switch msg.String() {
+		case "up", "k":
+			t.move(-1, rows)
+		case "down", "j":
+			t.move(1, rows)
+		case "pgup", "ctrl+u":
+			t.move(-rows, rows)
+		case "pgdown", "ctrl+d":
+			t.move(rows, rows)
+		case "home", "g":
+			t.move(-len(t.rows), rows)
+		case "end", "G":
+			t.move(len(t.rows), rows)
+		case "left", "h":
+			t.moveColumn(-1, width)
+		case "right", "l":
+			t.moveColumn(1, width)
+		case "s":
+			t.sortBy()
+		case "w":
+			t.expanded = !t.expanded
+			t.moveColumn(0, width)
+		default:
+			return m, nil
+		}
+	}
+
+	m.statusMsg = m.viewerStatus()
+	return m, nil
+}
@@ -0,0 +1,196 @@
+package ui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTableDelimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		want        rune
+	}{
+		{"people.csv", "", ','},
+		{"PEOPLE.CSV", "", ','},
+		{"people.tsv", "", '\t'},
+		{"people.tab", "", '\t'},
+		{"export", "text/csv; charset=utf-8", ','},
+		{"export", "text/tab-separated-values", '\t'},
+		{"people.csv", "text/plain", ','},
+		{"people.txt", "text/plain", 0},
+		{"people.csv.gz", "", 0},
+		{"export", "", 0},
+	}
+	for _, tt := range tests {
+		if got := tableDelimiter(tt.name, tt.contentType); got != tt.want {
+			t.Errorf("tableDelimiter(%q, %q) = %q, want %q", tt.name, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestReadTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		comma    rune
+		maxRows  int
+		header   []string
+		rows     [][]string
+		complete bool
+		wantErr  string
+	}{
+		{
+			name:     "csv",
+			content:  "name,age\nada,36\n\"bob, jr\",7\n",
+			comma:    ',',
+			maxRows:  10,
+			header:   []string{"name", "age"},
+			rows:     [][]string{{"ada", "36"}, {"bob, jr", "7"}},
+			complete: true,
+		},
+		{
+			name:     "tsv with ragged rows",
+			content:  "a\tb\n1\n1\t2\t3\n",
+			comma:    '\t',
+			maxRows:  10,
+			header:   []string{"a", "b"},
+			rows:     [][]string{{"1"}, {"1", "2", "3"}},
+			complete: true,
+		},
+		{
+			name:    "more rows than read",
+			content: "n\n1\n2\n3\n",
+			comma:   ',',
+			maxRows: 2,
+			header:  []string{"n"},
+			rows:    [][]string{{"1"}, {"2"}},
+		},
+		{
+			name:     "header only",
+			content:  "a,b\n",
+			comma:    ',',
+			maxRows:  10,
+			header:   []string{"a", "b"},
+			complete: true,
+		},
+		{
+			name:    "empty",
+			content: "",
+			comma:   ',',
+			maxRows: 10,
+			wantErr: "the file is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := readTable(strings.NewReader(tt.content), tt.comma, tt.maxRows)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rows [][]string
+			for _, row := range table.rows {
+				rows = append(rows, row.fields)
+			}
+			if !reflect.DeepEqual(table.header, tt.header) || !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("read %q and %q, want %q and %q", table.header, rows, tt.header, tt.rows)
+			}
+			if table.complete != tt.complete {
+				t.Errorf("complete = %v, want %v", table.complete, tt.complete)
+			}
+		})
+	}
+}
+
+// column returns the values of a column of the table in the order shown
+func column(table *tableView, i int) []string {
+	var values []string
+	for _, row := range table.rows {
+		values = append(values, field(row.fields, i))
+	}
+	return values
+}
+
+func TestTableSort(t *testing.T) {
+	content := "name,score,team\nbob,9,red\nada,10,\ncy,-2.5,blue\ndee,,red\n"
+	tests := []struct {
+		name    string
+		column  int
+		presses int
+		want    []string
+		header  string
+	}{
+		{"text ascending", 0, 1, []string{"ada", "bob", "cy", "dee"}, "name ↑"},
+		{"text descending", 0, 2, []string{"dee", "cy", "bob", "ada"}, "name ↓"},
+		{"back in file order", 0, 3, []string{"bob", "ada", "cy", "dee"}, "name"},
+		// Numbers sort by value, not as text, with empty cells as zero
+		{"numbers ascending", 1, 1, []string{"cy", "dee", "bob", "ada"}, "score ↑"},
+		{"numbers descending", 1, 2, []string{"ada", "bob", "dee", "cy"}, "score ↓"},
+		// Equal values keep their order
+		{"stable", 2, 1, []string{"ada", "cy", "bob", "dee"}, "team ↑"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := readTable(strings.NewReader(content), ',', 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			table.column = tt.column
+			for range tt.presses {
+				table.sortBy()
+			}
+			if got := column(table, 0); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("names = %v, want %v", got, tt.want)
+			}
+			if got := table.headerLabels()[tt.column]; got != tt.header {
+				t.Errorf("header = %q, want %q", got, tt.header)
+			}
+		})
+	}
+}
+
+func TestTableSortOtherColumn(t *testing.T) {
+	table, err := readTable(strings.NewReader("name,score\nbob,9\nada,10\n"), ',', 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.sortBy()
+	table.sortBy()
+
+	// Sorting by another column starts ascending again
+	table.column = 1
+	table.sortBy()
+	if got, want := column(table, 1), []string{"9", "10"}; !reflect.DeepEqual(got, want) || table.sortDesc {
+		t.Errorf("scores = %v descending %v, want %v ascending", got, table.sortDesc, want)
+	}
+	if got := table.headerLabels(); got[0] != "name" {
+		t.Errorf("the previously sorted column is still marked: %q", got)
+	}
+}
+
+func TestViewTable(t *testing.T) {
+	b := testStore()
+	m := newTestModel(t, b, "photos")
+	m = viewObject(t, m, b, "scores.csv", "name,score\nbob,9\nada,10\ncy,1\n")
+	table := m.file.table
+	if table == nil {
+		t.Fatal("scores.csv is not shown as a table")
+	}
+
+	for _, k := range []string{"l", "s", "s"} {
+		m, _ = press(m, k)
+	}
+	if got, want := column(table, 0), []string{"ada", "bob", "cy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("names = %v after sorting by score descending, want %v", got, want)
+	}
+	if !strings.Contains(m.file.table.view(120, 10), "score ↓") {
+		t.Error("the sorted column is not marked in the header")
+	}
+}
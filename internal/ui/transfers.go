@@ -0,0 +1,249 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fernandoabolafio/lazybucket/internal/transfer"
+)
+
+var (
+	transferSelectedStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#FFFFFF")).
+				Background(lipgloss.Color("#3C3C3C"))
+
+	transferStateStyles = map[transfer.State]lipgloss.Style{
+		transfer.Queued:    lipgloss.NewStyle().Foreground(lipgloss.Color("#727272")),
+		transfer.Running:   lipgloss.NewStyle().Foreground(lipgloss.Color("#4A86CF")),
+		transfer.Paused:    lipgloss.NewStyle().Foreground(lipgloss.Color("#CFA84A")),
+		transfer.Done:      lipgloss.NewStyle().Foreground(lipgloss.Color("#4ACF6B")),
+		transfer.Failed:    lipgloss.NewStyle().Foreground(lipgloss.Color("#CF4A4A")),
+		transfer.Cancelled: lipgloss.NewStyle().Foreground(lipgloss.Color("#727272")),
+	}
+)
+
+// transferBatch counts the transfers that finished since the queue was last
+// idle, so that a single summary can be shown once it drains
+type transferBatch struct {
+	done      int
+	failed    int
+	cancelled int
+	lastErr   error
+}
+
+// watchTransfers starts polling the transfer queue unless it is already
+// being polled
+func (m Model) watchTransfers() (Model, tea.Cmd) {
+	if m.watching {
+		return m, nil
+	}
+	m.watching = true
+	return m, tickTransfers()
+}
+
+func tickTransfers() tea.Cmd {
+	return tea.Tick(progressInterval, func(time.Time) tea.Msg {
+		return transferTickMsg{}
+	})
+}
+
+// pollTransfers collects finished transfers, reloads the current path once
+// nothing is being written into it any more and stops polling when the
+// queue is idle
+func (m Model) pollTransfers() (Model, tea.Cmd) {
+	for _, snap := range m.queue.TakeFinished() {
+		switch snap.State {
+		case transfer.Done:
+			m.batch.done++
+			if snap.Kind != transfer.Download && snap.Dest == m.currentPath {
+				m.refreshPath = snap.Dest
+			}
+		case transfer.Failed:
+			m.batch.failed++
+			m.batch.lastErr = snap.Err
+		case transfer.Cancelled:
+			m.batch.cancelled++
+		}
+	}
+
+	idle := m.queue.Stats().Active() == 0
+	if idle {
+		m.watching = false
+		if m.batch != (transferBatch{}) {
+			m.statusMsg = transferSummary(m.batch)
+			m.batch = transferBatch{}
+		}
+	}
+
+	var cmd tea.Cmd
+	if m.refreshPath != "" && m.refreshPath == m.currentPath && !m.viewingFile && !m.writingInto(m.currentPath) {
+		m.refreshPath = ""
+		m, cmd = m.reloadWithStatus(m.statusMsg)
+	}
+
+	if idle {
+		return m, cmd
+	}
+	return m, tea.Batch(cmd, tickTransfers())
+}
+
+// writingInto reports whether an unfinished upload, copy or move writes
+// into path
+func (m Model) writingInto(path string) bool {
+	for _, snap := range m.queue.List() {
+		if snap.Kind != transfer.Download && snap.Dest == path && !snap.State.Finished() {
+			return true
+		}
+	}
+	return false
+}
+
+// transferSummary describes a batch of finished transfers
+func transferSummary(b transferBatch) string {
+	s := fmt.Sprintf("Transfers finished: %d done", b.done)
+	if b.cancelled > 0 {
+		s += fmt.Sprintf(", %d cancelled", b.cancelled)
+	}
+	if b.failed > 0 {
+		s += fmt.Sprintf(", %d failed (last error: %v), press t for details", b.failed, b.lastErr)
+	}
+	return s
+}
+
+// transferStatus describes the unfinished transfers next to the progress bar
+func transferStatus(stats transfer.Stats) string {
+	parts := []string{fmt.Sprintf("%d running", stats.Running)}
+	if stats.Queued > 0 {
+		parts = append(parts, fmt.Sprintf("%d queued", stats.Queued))
+	}
+	if stats.Paused > 0 {
+		parts = append(parts, fmt.Sprintf("%d paused", stats.Paused))
+	}
+	return fmt.Sprintf("Transfers: %s · %s of %s at %s/s · t: show",
+		strings.Join(parts, ", "), formatSize(stats.Bytes), formatSize(stats.Size), formatSize(stats.Rate))
+}
+
+// renderTransfers renders the transfer panel, keeping the cursor in view
+func (m Model) renderTransfers() string {
+	var s strings.Builder
+	s.WriteString(pickerHeaderStyle.Render("Transfers (p: pause/resume, c: cancel, r: retry, C: cancel all, X: clear finished, esc: close)"))
+	s.WriteString("\n\n")
+
+	snaps := m.queue.List()
+	if len(snaps) == 0 {
+		s.WriteString("No transfers yet")
+		return s.String()
+	}
+
+	rows := max(1, m.height-8)
+	start := 0
+	if m.transferCursor >= rows {
+		start = m.transferCursor - rows + 1
+	}
+	end := min(len(snaps), start+rows)
+
+	row := lipgloss.NewStyle().MaxWidth(max(20, m.width))
+	for i := start; i < end; i++ {
+		line := renderTransfer(snaps[i])
+		if i == m.transferCursor {
+			line = transferSelectedStyle.Render("> " + line)
+		} else {
+			line = "  " + line
+		}
+		s.WriteString(row.Render(line))
+		s.WriteString("\n")
+	}
+
+	stats := m.queue.Stats()
+	s.WriteString(fmt.Sprintf("\n%d transfers: %d running, %d queued, %d paused, %d done, %d failed, %d cancelled",
+		len(snaps), stats.Running, stats.Queued, stats.Paused, stats.Done, stats.Failed, stats.Cancelled))
+	return s.String()
+}
+
+// renderTransfer renders a single line of the transfer panel
+func renderTransfer(snap transfer.Snapshot) string {
+	state := transferStateStyles[snap.State].Render(fmt.Sprintf("%-9s", snap.State))
+
+	percent := ""
+	if snap.Size > 0 {
+		percent = fmt.Sprintf("%d%%", min(100, snap.Bytes*100/snap.Size))
+	}
+	rate := ""
+	if snap.State == transfer.Running {
+		rate = formatSize(snap.Rate()) + "/s"
+	}
+
+	line := fmt.Sprintf("%s %-8s %4s %21s %11s  %s", state, snap.Kind, percent,
+		formatSize(snap.Bytes)+"/"+formatSize(snap.Size), rate, snap.Name)
+	switch {
+	case snap.State == transfer.Failed && snap.Err != nil:
+		line += " (" + snap.Err.Error() + ")"
+	case snap.Note != "":
+		line += " (" + snap.Note + ")"
+	}
+	return line
+}
+
+// updateTransferPanel handles keys while the transfer panel is open
+func (m Model) updateTransferPanel(msg tea.KeyMsg) (Model, tea.Cmd) {
+	snaps := m.queue.List()
+	m.transferCursor = max(0, min(m.transferCursor, len(snaps)-1))
+
+	var selected *transfer.Snapshot
+	if len(snaps) > 0 {
+		selected = &snaps[m.transferCursor]
+	}
+
+	var err error
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m.quit()
+	case "esc", "t":
+		m.showTransfers = false
+		return m, nil
+	case "up", "k":
+		m.transferCursor = max(0, m.transferCursor-1)
+		return m, nil
+	case "down", "j":
+		m.transferCursor = max(0, min(m.transferCursor+1, len(snaps)-1))
+		return m, nil
+	case "p":
+		if selected == nil {
+			return m, nil
+		}
+		if selected.State == transfer.Paused {
+			err = m.queue.Resume(selected.ID)
+		} else {
+			err = m.queue.Pause(selected.ID)
+		}
+	case "c":
+		if selected == nil {
+			return m, nil
+		}
+		err = m.queue.Cancel(selected.ID)
+	case "r":
+		if selected == nil {
+			return m, nil
+		}
+		err = m.queue.Retry(selected.ID)
+	case "C":
+		m.queue.CancelAll()
+	case "X":
+		m.queue.ClearFinished()
+		m.transferCursor = 0
+	default:
+		return m, nil
+	}
+
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("Error: %v", err)
+		return m, nil
+	}
+	return m.watchTransfers()
+}
+
+type transferTickMsg struct{}
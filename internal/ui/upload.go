@@ -0,0 +1,139 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fernandoabolafio/lazybucket/internal/backend"
+	"github.com/fernandoabolafio/lazybucket/internal/transfer"
+)
+
+// uploadFile is a local file queued for upload
+type uploadFile struct {
+	localPath  string
+	objectName string
+	size       int64
+}
+
+// newUploadPicker creates a file picker for choosing what to upload. Enter
+// selects a file or folder, l/→ opens a folder and Esc closes the picker.
+func newUploadPicker(height int) filepicker.Model {
+	picker := filepicker.New()
+	picker.DirAllowed = true
+	picker.FileAllowed = true
+	picker.ShowHidden = true
+	picker.AutoHeight = false
+	picker.Height = height
+	picker.KeyMap.Back = key.NewBinding(
+		key.WithKeys("h", "backspace", "left"),
+		key.WithHelp("h", "back"),
+	)
+
+	if cwd, err := os.Getwd(); err == nil {
+		picker.CurrentDirectory = cwd
+	}
+
+	return picker
+}
+
+// collectUploads lists the files to upload for a local file or directory.
+// Directories are uploaded recursively below a prefix named after them.
+func collectUploads(localPath, prefix string) ([]uploadFile, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	base := filepath.Base(localPath)
+	if !info.IsDir() {
+		return []uploadFile{{localPath: localPath, objectName: prefix + base, size: info.Size()}}, nil
+	}
+
+	var files []uploadFile
+	err = filepath.WalkDir(localPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(localPath, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, uploadFile{
+			localPath:  p,
+			objectName: prefix + base + "/" + filepath.ToSlash(rel),
+			size:       info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// uploadPath lists the files to upload for a local file or directory
+// into the current path
+func (m Model) uploadPath(localPath string) tea.Cmd {
+	ctx := m.planning.ctx
+	destPath := m.currentPath
+	_, prefix := backend.ParsePath(destPath)
+	prefix = backend.NormalizePrefix(prefix)
+
+	return func() tea.Msg {
+		files, err := collectUploads(localPath, prefix)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg{err}
+		}
+		return uploadPlanMsg{path: destPath, files: files}
+	}
+}
+
+// queueUploads queues a transfer for every file of an upload
+func (m Model) queueUploads(plan uploadPlanMsg) (Model, tea.Cmd) {
+	bucketName, _ := backend.ParsePath(plan.path)
+	for _, f := range plan.files {
+		m.queue.Add(transfer.Upload, m.backend.URL(bucketName, f.objectName), plan.path, f.size,
+			func(ctx context.Context, p *transfer.Progress) error {
+				return uploadOne(ctx, m.backend, bucketName, f, p)
+			})
+	}
+
+	m.statusMsg = fmt.Sprintf("Queued %d uploads to %s", len(plan.files), plan.path)
+	return m.watchTransfers()
+}
+
+// uploadOne uploads a single local file, counting the bytes sent
+func uploadOne(ctx context.Context, b backend.Backend, bucketName string, f uploadFile, progress io.Writer) error {
+	file, err := os.Open(f.localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return b.Upload(ctx, bucketName, f.objectName, io.TeeReader(file, progress), f.size)
+}
+
+type uploadPlanMsg struct {
+	path  string
+	files []uploadFile
+}
@@ -0,0 +1,338 @@
+package ui
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxHighlightSize is the largest content that is syntax highlighted.
+// Larger files are shown as plain text, since highlighting them takes too
+// long to keep the viewer responsive.
+const maxHighlightSize = 1 << 20
+
+var (
+	lineNumberStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#626262"))
+
+	highlightStyle = styles.Get("monokai")
+)
+
+// fileView is the content of the viewed file and how it is rendered
+type fileView struct {
+	name        string
+	content     string
+	lexer       chroma.Lexer
+	highlight   bool
+	lineNumbers bool
+
+	// tree and table are structured views of JSON and tabular content,
+	// shown while structured is set. parseErr tells why content could not
+	// be shown as either.
+	tree       *jsonView
+	table      *tableView
+	structured bool
+	parseErr   error
+
+	// hex is set for binary objects, which are only shown as a hex dump,
+	// and paged for text objects too large to be read in full
+	hex   *hexView
+	paged *pagedView
+
+	// compression is set for objects that were decompressed for viewing
+	// and member for files read from an archive
+	compression *compression
+	member      *memberSource
+
+	// search holds the matches of the last search in the text, whose lines
+	// are cached in cache since every step through the matches renders the
+	// text again
+	search *textSearch
+	cache  *lineCache
+}
+
+// lineCache holds the plain and highlighted lines of a file once rendered
+type lineCache struct {
+	plain       []string
+	highlighted []string
+}
+
+// newFileView prepares a loaded file for viewing. The language is detected
+// from the object's name, without any compression extension, falling back
+// to its Content-Type.
+func newFileView(msg fileLoadedMsg) fileView {
+	name := msg.name
+	if msg.compression != nil {
+		name = decompressedName(name)
+	}
+	v := fileView{
+		name:        msg.name,
+		content:     msg.content,
+		lexer:       detectLexer(name, msg.contentType),
+		highlight:   true,
+		lineNumbers: true,
+		table:       msg.table,
+		structured:  msg.table != nil,
+		parseErr:    msg.tableErr,
+		hex:         msg.hex,
+		paged:       msg.paged,
+		compression: msg.compression,
+		member:      msg.member,
+		cache:       &lineCache{},
+	}
+
+	// JSON opens as a tree, or as highlighted text if it does not parse
+	if isJSON(name, msg.contentType) {
+		docs, err := parseJSON(msg.content)
+		if err != nil {
+			v.parseErr = err
+			return v
+		}
+		v.tree = newJSONView(docs)
+		v.structured = true
+	}
+	return v
+}
+
+// detectLexer returns the lexer for an object, or nil for plain text
+func detectLexer(name, contentType string) chroma.Lexer {
+	if lexer := lexers.Match(path.Base(name)); lexer != nil {
+		return lexer
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if lexer := lexers.MatchMimeType(mediaType); lexer != nil {
+			return lexer
+		}
+	}
+	return nil
+}
+
+// language returns the name of the detected language, or "" for plain text
+func (v fileView) language() string {
+	if v.lexer == nil {
+		return ""
+	}
+	return v.lexer.Config().Name
+}
+
+// canHighlight reports whether the content can be syntax highlighted
+func (v fileView) canHighlight() bool {
+	return v.lexer != nil && len(v.content) <= maxHighlightSize
+}
+
+// render renders the content for the viewport, highlighted and numbered as
+// enabled
+func (v fileView) render() string {
+	var lines []string
+	if v.highlight && v.canHighlight() {
+		lines = v.highlightLines()
+	}
+	if lines == nil {
+		lines = v.plainLines()
+	}
+	if v.search != nil {
+		lines = v.search.mark(lines, v.plainLines())
+	}
+	if !v.lineNumbers {
+		return strings.Join(lines, "\n")
+	}
+
+	width := len(fmt.Sprint(len(lines)))
+	var s strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		s.WriteString(lineNumberStyle.Render(fmt.Sprintf("%*d │ ", width, i+1)))
+		s.WriteString(line)
+	}
+	return s.String()
+}
+
+// plainLines splits the content into lines
+func (v fileView) plainLines() []string {
+	if v.cache.plain == nil {
+		v.cache.plain = strings.Split(strings.TrimSuffix(v.content, "\n"), "\n")
+	}
+	return v.cache.plain
+}
+
+// highlightLines highlights the content line by line, so that no colour
+// runs on into the line numbers. It returns nil if highlighting fails.
+func (v fileView) highlightLines() []string {
+	if v.cache.highlighted != nil {
+		return v.cache.highlighted
+	}
+
+	iterator, err := chroma.Coalesce(v.lexer).Tokenise(nil, v.content)
+	if err != nil {
+		return nil
+	}
+
+	tokenLines := chroma.SplitTokensIntoLines(iterator.Tokens())
+	if n := len(tokenLines); n > 0 && len(tokenLines[n-1]) == 0 {
+		tokenLines = tokenLines[:n-1]
+	}
+
+	lines := make([]string, len(tokenLines))
+	for i, tokens := range tokenLines {
+		if n := len(tokens); n > 0 {
+			tokens[n-1].Value = strings.TrimSuffix(tokens[n-1].Value, "\n")
+		}
+
+		var s strings.Builder
+		if err := formatters.TTY256.Format(&s, highlightStyle, chroma.Literator(tokens...)); err != nil {
+			return nil
+		}
+		lines[i] = s.String()
+	}
+	v.cache.highlighted = lines
+	return lines
+}
+
+// viewerStatus describes the viewed file and how it is rendered, and
+// where it was read from and how it was decompressed
+func (m Model) viewerStatus() string {
+	status := m.contentStatus()
+	if s := m.file.member; s != nil {
+		status += " " + s.describe()
+	}
+	if c := m.file.compression; c != nil {
+		status += " " + c.describe()
+	}
+	if s := m.shownSearch(); s != nil {
+		status += " " + s.describe()
+	}
+	if p := m.file.paged; p != nil && p.search != nil {
+		status += " " + p.search.describe()
+	}
+	return status
+}
+
+// contentStatus describes the viewed content and how it is rendered
+func (m Model) contentStatus() string {
+	switch {
+	case m.file.hex != nil:
+		return m.hexStatus()
+	case m.file.paged != nil:
+		return m.pagedStatus()
+	case m.file.structured && m.file.table != nil:
+		return m.tableStatus()
+	case m.file.structured:
+		return m.jsonStatus()
+	}
+
+	language := m.file.language()
+	switch {
+	case m.file.parseErr != nil:
+		return fmt.Sprintf("Viewing %s (%v)", m.file.name, m.file.parseErr)
+	case language == "":
+		return fmt.Sprintf("Viewing %s", m.file.name)
+	case !m.file.canHighlight():
+		return fmt.Sprintf("Viewing %s (%s, too large to highlight)", m.file.name, language)
+	case !m.file.highlight:
+		return fmt.Sprintf("Viewing %s (%s, highlighting off)", m.file.name, language)
+	}
+	return fmt.Sprintf("Viewing %s (%s)", m.file.name, language)
+}
+
+// updateViewer handles keys while a file is viewed
+func (m Model) updateViewer(msg tea.KeyMsg) (Model, tea.Cmd) {
+	if m.querying && m.file.hex != nil {
+		return m.updateGoToOffset(msg)
+	}
+	if m.querying {
+		return m.updateQuery(msg)
+	}
+	if m.searching {
+		return m.updateSearch(msg)
+	}
+	switch {
+	case m.file.hex != nil:
+		return m.updateHexView(msg)
+	case m.file.paged != nil:
+		return m.updatePagedView(msg)
+	case m.file.structured && m.file.table != nil:
+		return m.updateTableView(msg)
+	case m.file.structured:
+		return m.updateJSONView(msg)
+	}
+
+	switch {
+	case key.Matches(msg, m.keyMap.Quit):
+		return m.quit()
+	case key.Matches(msg, m.keyMap.Back):
+		m.viewingFile = false
+		return m, nil
+	case key.Matches(msg, m.keyMap.Highlight):
+		if !m.file.canHighlight() {
+			m.statusMsg = "No syntax highlighting for this file"
+			return m, nil
+		}
+		m.file.highlight = !m.file.highlight
+		m.viewport.SetContent(m.file.render())
+		m.statusMsg = m.viewerStatus()
+		return m, nil
+	case key.Matches(msg, m.keyMap.LineNumbers):
+		m.file.lineNumbers = !m.file.lineNumbers
+		m.viewport.SetContent(m.file.render())
+		return m, nil
+	case key.Matches(msg, m.keyMap.Structured):
+		if m.file.tree == nil && m.file.table == nil {
+			m.statusMsg = "No tree or table view for this file"
+			return m, nil
+		}
+		m.file.structured = true
+		m.statusMsg = m.viewerStatus()
+		return m, nil
+	case key.Matches(msg, m.keyMap.Query) && m.file.tree != nil:
+		return m.updateJSONView(msg)
+	case key.Matches(msg, m.keyMap.Search):
+		return m.startSearch()
+	case key.Matches(msg, m.keyMap.NextMatch), key.Matches(msg, m.keyMap.PrevMatch):
+		if m.file.search == nil {
+			m.statusMsg = "Nothing searched yet, press / to search"
+			return m, nil
+		}
+		delta := 1
+		if key.Matches(msg, m.keyMap.PrevMatch) {
+			delta = -1
+		}
+		m.file.search.step(delta)
+		return m.showMatch(), nil
+	case key.Matches(msg, m.keyMap.Cancel) && m.file.search != nil:
+		// Clear the highlighted matches
+		m.file.search = nil
+		return m.showMatch(), nil
+	}
+
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+// viewerSize returns the width and height available to the viewer's content
+func (m Model) viewerSize() (int, int) {
+	return max(1, m.viewport.Width-viewportStyle.GetHorizontalFrameSize()),
+		max(1, m.viewport.Height-viewportStyle.GetVerticalFrameSize())
+}
+
+// renderViewer frames content the way the viewport frames file content
+func (m Model) renderViewer(content string) string {
+	width, height := m.viewerSize()
+	return viewportStyle.Render(lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		MaxWidth(width).
+		MaxHeight(height).
+		Render(content))
+}